@@ -0,0 +1,108 @@
+// MIT License
+//
+// Copyright (c) 2019 Gregory Meyer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+const errAutosave = "Could not autosave!"
+
+// Autosave saves a Session to a data file after a number of changes, or
+// periodically while it has unsaved changes
+type Autosave struct {
+	filename     string
+	everyChanges int
+	numChanges   int
+}
+
+// NewAutosave creates an Autosave for a Session that saves to a data file
+// after every everyChanges changes, or never if everyChanges is not positive
+func NewAutosave(session *Session, filename string, everyChanges int) *Autosave {
+	autosave := &Autosave{filename, everyChanges, 0}
+	session.autosave = autosave
+
+	return autosave
+}
+
+// Filename returns the name of the data file this Autosave saves to
+func (a *Autosave) Filename() string {
+	return a.filename
+}
+
+// Changed counts a change to the Session, saving it if enough changes have
+// accumulated
+func (a *Autosave) Changed(session *Session) error {
+	a.numChanges++
+
+	if a.everyChanges <= 0 || a.numChanges < a.everyChanges || !session.dirty {
+		return nil
+	}
+
+	return session.SaveFile(a.filename)
+}
+
+// newTicker delivers the time on a channel every interval until the returned
+// function is called. Tests replace it so that they decide when time passes.
+var newTicker = func(interval time.Duration) (<-chan time.Time, func()) {
+	ticker := time.NewTicker(interval)
+
+	return ticker.C, ticker.Stop
+}
+
+// Every saves the Session each interval while it has unsaved changes, until
+// the returned function is called. Errors are written to errOut.
+func (a *Autosave) Every(session *Session, interval time.Duration, errOut io.Writer) (stop func()) {
+	ticks, stopTicker := newTicker(interval)
+	done := make(chan struct{})
+	exited := make(chan struct{})
+
+	go func() {
+		defer close(exited)
+
+		for {
+			select {
+			case <-ticks:
+				session.mutex.Lock()
+
+				if session.dirty {
+					if err := session.SaveFile(a.filename); err != nil {
+						fmt.Fprintf(errOut, "Could not autosave to %s: %v\n", a.filename, err)
+					}
+				}
+
+				session.mutex.Unlock()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		stopTicker()
+		close(done)
+		<-exited
+	}
+}
@@ -0,0 +1,174 @@
+// MIT License
+//
+// Copyright (c) 2019 Gregory Meyer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scriptedInput feeds Dispatcher.Run one line per read, first calling the
+// line's function if it has one
+type scriptedInput struct {
+	lines []scriptedLine
+}
+
+type scriptedLine struct {
+	before func()
+	line   string
+}
+
+func (s *scriptedInput) Read(p []byte) (int, error) {
+	if len(s.lines) == 0 {
+		return 0, io.EOF
+	}
+
+	next := s.lines[0]
+	s.lines = s.lines[1:]
+
+	if next.before != nil {
+		next.before()
+	}
+
+	return copy(p, next.line+"\n"), nil
+}
+
+// savedData returns the data file an Autosave wrote, or "" if there isn't one
+func savedData(t *testing.T, filename string) string {
+	t.Helper()
+
+	contents, err := ioutil.ReadFile(filename)
+
+	if os.IsNotExist(err) {
+		return ""
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(contents)
+}
+
+func TestAutosaveEveryIntervalAndQuitPrompt(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	ticks := make(chan time.Time)
+
+	defer func(start func(time.Duration) (<-chan time.Time, func())) { newTicker = start }(newTicker)
+	newTicker = func(time.Duration) (<-chan time.Time, func()) { return ticks, func() {} }
+
+	// the second tick can't be delivered until the first one's save is done
+	tick := func() {
+		ticks <- time.Time{}
+		ticks <- time.Time{}
+	}
+
+	filename := filepath.Join(dir, "data.txt")
+	session := NewSession()
+	session.interactive = true
+	stop := NewAutosave(session, filename, 0).Every(session, time.Minute, ioutil.Discard)
+	defer stop()
+
+	var data []string
+	snapshot := func() { data = append(data, savedData(t, filename)) }
+
+	input := &scriptedInput{[]scriptedLine{
+		{nil, "ar DVD Alien"},
+		{func() { snapshot(); tick(); snapshot() }, "ar DVD Brazil"},
+		{nil, "qq"},
+		{nil, "c"},
+		{func() { tick(); snapshot() }, "ar DVD Casablanca"},
+		{nil, "qq"},
+		{nil, "y"},
+	}}
+
+	var out bytes.Buffer
+	NewCommandDispatcher().Run(session, input, &out)
+	snapshot()
+
+	if strings.Contains(data[0], "Alien") {
+		t.Errorf("saved before any time passed:\n%s", data[0])
+	}
+
+	if !strings.Contains(data[1], "Alien") || strings.Contains(data[1], "Brazil") {
+		t.Errorf("autosave after Alien saved:\n%s", data[1])
+	}
+
+	if !strings.Contains(data[2], "Brazil") || strings.Contains(data[2], "Casablanca") {
+		t.Errorf("autosave after cancelling the quit saved:\n%s", data[2])
+	}
+
+	if !strings.Contains(data[3], "Casablanca") {
+		t.Errorf("quitting with y saved:\n%s", data[3])
+	}
+
+	if prompts := strings.Count(out.String(), "Save them before quitting?"); prompts != 2 {
+		t.Errorf("asked to save %d times, want 2:\n%s", prompts, out.String())
+	}
+}
+
+func TestAutosaveAfterChanges(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "data.txt")
+	session, dispatcher := NewSession(), NewCommandDispatcher()
+	NewAutosave(session, filename, 2)
+
+	runCommand(t, session, dispatcher, "ar DVD Alien")
+
+	if data := savedData(t, filename); data != "" {
+		t.Fatalf("saved after one change of two:\n%s", data)
+	}
+
+	runCommand(t, session, dispatcher, "ar DVD Brazil")
+
+	if data := savedData(t, filename); !strings.Contains(data, "Brazil") {
+		t.Fatalf("saved after two changes:\n%s", data)
+	}
+
+	runCommand(t, session, dispatcher, "ar DVD Casablanca")
+
+	if data := savedData(t, filename); strings.Contains(data, "Casablanca") {
+		t.Errorf("saved again after one more change:\n%s", data)
+	}
+}
+
+func TestQuitWithoutSaving(t *testing.T) {
+	session := NewSession()
+	session.interactive = true
+
+	var out bytes.Buffer
+	input := &scriptedInput{[]scriptedLine{{nil, "ar DVD Alien"}, {nil, "qq"}, {nil, "n"}}}
+	NewCommandDispatcher().Run(session, input, &out)
+
+	if !strings.HasSuffix(out.String(), "Done\n") || strings.Contains(out.String(), "Data saved") {
+		t.Errorf("quitting with n printed:\n%s", out.String())
+	}
+}
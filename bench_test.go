@@ -0,0 +1,208 @@
+// MIT License
+//
+// Copyright (c) 2019 Gregory Meyer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+var benchWords = strings.Fields(`the a of and in war peace house great king queen
+	night day man woman star city river mountain love death life story return
+	empire last first dark light blue red golden silent secret lost hidden
+	wild little big old new long short street road ship island garden world`)
+
+var benchQueries = []string{"golden", "silent river", "zzz"}
+
+// benchRecords is how many Records the benchmarks' Library has
+const benchRecords = 100000
+
+func TestIndexedSearchMatchesLinear(t *testing.T) {
+	library := generateLibrary(10000)
+
+	for _, query := range benchQueries {
+		matcher := NewSubstringMatcher(query)
+
+		if linear, indexed := library.searchLinear(matcher), library.Search(matcher); len(linear) != len(indexed) {
+			t.Errorf("fs %q: linear search found %d records, indexed search found %d",
+				query, len(linear), len(indexed))
+		}
+	}
+}
+
+func BenchmarkSearchLinear(b *testing.B) {
+	library := generateLibrary(benchRecords)
+
+	for _, query := range benchQueries {
+		matcher := NewSubstringMatcher(query)
+
+		b.Run(query, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				library.searchLinear(matcher)
+			}
+		})
+	}
+}
+
+func BenchmarkSearchIndexed(b *testing.B) {
+	library := generateLibrary(benchRecords)
+
+	for _, query := range benchQueries {
+		matcher := NewSubstringMatcher(query)
+
+		b.Run(query, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				library.Search(matcher)
+			}
+		})
+	}
+}
+
+func BenchmarkListByTitleSortingEveryTime(b *testing.B) {
+	library := generateLibrary(benchRecords)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		SprintRecords(sortEveryTime(library, TitleLess))
+	}
+}
+
+func BenchmarkListByTitleInMaintainedOrder(b *testing.B) {
+	library := generateLibrary(benchRecords)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		SprintRecords(library.sortedRecords())
+	}
+}
+
+func BenchmarkListByRatingSortingEveryTime(b *testing.B) {
+	library := generateLibrary(benchRecords)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		SprintRecords(sortEveryTime(library, RatingLess))
+	}
+}
+
+func BenchmarkListByRatingInMaintainedOrder(b *testing.B) {
+	library := generateLibrary(benchRecords)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		SprintRecords(library.inRatingOrder.Records())
+	}
+}
+
+const benchPageSize = 20
+
+func BenchmarkPageSortingEveryTime(b *testing.B) {
+	library := generateLibrary(benchRecords)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		sorted := sortEveryTime(library, TitleLess)
+		start := len(sorted) / 2
+		SprintRecords(sorted[start:minInt(start+benchPageSize, len(sorted))])
+	}
+}
+
+func BenchmarkPageInMaintainedOrder(b *testing.B) {
+	library := generateLibrary(benchRecords)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		SprintRecords(library.inTitleOrder.Page(library.NumRecords()/2, benchPageSize))
+	}
+}
+
+// sortEveryTime lists the Records of a Library the way it was done before the
+// Library kept them in order: gather them from a map, then sort them
+func sortEveryTime(library *Library, less func(a, b *Record) bool) []*Record {
+	records := make([]*Record, 0, len(library.byID))
+
+	for _, record := range library.byID {
+		records = append(records, record)
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return less(records[i], records[j])
+	})
+
+	return records
+}
+
+// generateLibrary creates a Library of Records with random titles, the same
+// ones on every run
+func generateLibrary(numRecords int) *Library {
+	library := NewLibrary()
+	random := rand.New(rand.NewSource(1))
+	mediums := []string{"DVD", "VHS", "Book", "CD"}
+
+	// some Records are left unrated
+	ratings := append(library.Scale().Values(), NoRating)
+
+	for library.NumRecords() < numRecords {
+		words := make([]string, 2+random.Intn(4))
+
+		for i := range words {
+			words[i] = benchWords[random.Intn(len(benchWords))]
+		}
+
+		title := fmt.Sprintf("%s %d", strings.Join(words, " "), random.Intn(1000))
+		id, err := library.AddRecord(mediums[random.Intn(len(mediums))], title)
+
+		if err == nil {
+			_ = library.SetRating(library.byID[id], unattributedUser, ratings[random.Intn(len(ratings))], nil)
+		}
+	}
+
+	return library
+}
+
+func BenchmarkRestore(b *testing.B) {
+	session := NewSession()
+	*session.library = *generateLibrary(benchRecords)
+	_ = session.catalog.AddCollection("some", time.Time{})
+	collection, _ := session.catalog.FindCollection("some")
+
+	for id := 1; id <= benchRecords; id += 10 {
+		_ = collection.AddMember(session.library.byID[id], time.Time{})
+	}
+
+	var saved bytes.Buffer
+	session.Save(&saved)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := NewSession().Restore(bufio.NewReader(bytes.NewReader(saved.Bytes()))); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
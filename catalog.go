@@ -28,22 +28,25 @@ import (
 	"io"
 	"sort"
 	"strings"
+	"time"
 )
 
 type catalogCollections map[string]*Collection
 
-// Catalog is a set of named Collections
+// Catalog is a set of named Collections, kept in order of name
 type Catalog struct {
 	collections catalogCollections
+	names       []string
 }
 
 // NewCatalog creates a Catalog ready to track Collections
 func NewCatalog() *Catalog {
-	return &Catalog{make(catalogCollections)}
+	return &Catalog{make(catalogCollections), nil}
 }
 
-// RestoreCatalog deserializes a Catalog from a *bufio.Reader
-func RestoreCatalog(reader *bufio.Reader, library *Library) (*Catalog, Error) {
+// RestoreCatalog deserializes a Catalog of Records in a Library from a
+// *bufio.Reader, in a file of a save format version
+func RestoreCatalog(reader *bufio.Reader, library *Library, version int) (*Catalog, Error) {
 	numCollections, err := ReadInt(reader)
 
 	if err != nil || numCollections < 0 {
@@ -53,7 +56,7 @@ func RestoreCatalog(reader *bufio.Reader, library *Library) (*Catalog, Error) {
 	catalog := NewCatalog()
 
 	for i := 0; i < numCollections; i++ {
-		collection, err := RestoreCollection(reader, library)
+		collection, err := RestoreCollection(reader, library, version)
 
 		if err != nil {
 			return nil, err
@@ -63,7 +66,21 @@ func RestoreCatalog(reader *bufio.Reader, library *Library) (*Catalog, Error) {
 			return nil, NewlineError(ErrInvalidFile)
 		}
 
-		catalog.collections[collection.name] = collection
+		catalog.insert(collection)
+	}
+
+	for _, collection := range catalog.collections {
+		if collection.parentName == "" {
+			continue
+		}
+
+		parent, ok := catalog.collections[collection.parentName]
+
+		if !ok || catalog.nest(collection, parent) != nil {
+			return nil, NewlineError(ErrInvalidFile)
+		}
+
+		collection.parentName = ""
 	}
 
 	return catalog, nil
@@ -89,27 +106,34 @@ func (c *Catalog) NumCollections() int {
 	return len(c.collections)
 }
 
-// AddCollection adds a Collection to a Catalog
-func (c *Catalog) AddCollection(name string) Error {
+// AddCollection adds a Collection created at a time to a Catalog
+func (c *Catalog) AddCollection(name string, at time.Time) Error {
 	if _, ok := c.collections[name]; ok {
 		return NewlineError(errDuplicateCollection)
 	}
 
-	c.collections[name] = NewCollection(name)
+	c.insert(NewCollection(name, at))
 
 	return nil
 }
 
-// DeleteCollection removes a Collection from a Catalog
-func (c *Catalog) DeleteCollection(name string) Error {
+// DeleteCollection removes a Collection from a Catalog. The Collections nested
+// inside it move up to its parent, modified at a time.
+func (c *Catalog) DeleteCollection(name string, at time.Time) Error {
 	collection, ok := c.collections[name]
 
 	if !ok {
 		return NewlineError(errNoSuchCollection)
 	}
 
+	for _, child := range collection.Children() {
+		_ = c.nest(child, collection.parent)
+		child.touch(at)
+	}
+
+	_ = c.nest(collection, nil)
 	clearCollection(collection)
-	delete(c.collections, name)
+	c.remove(collection)
 
 	return nil
 }
@@ -160,41 +184,196 @@ func (c *Catalog) CollectionStatistics() (numOne int, numMany int, total int) {
 	return numOne, numMany, total
 }
 
+// SharedRecord is a Record that is in more than one Collection
+type SharedRecord struct {
+	record *Record
+
+	// names are the names of the Collections containing the Record, in
+	// ascending order
+	names []string
+}
+
+// SharedRecords returns the Records that are in more than one Collection,
+// sorted by title in ascending order
+func (c *Catalog) SharedRecords() []SharedRecord {
+	names := make(map[*Record][]string)
+
+	for _, collection := range c.sortedCollections() {
+		for _, record := range collection.members {
+			if record.numCollections > 1 {
+				names[record] = append(names[record], collection.name)
+			}
+		}
+	}
+
+	shared := make([]SharedRecord, 0, len(names))
+
+	for record, recordNames := range names {
+		shared = append(shared, SharedRecord{record, recordNames})
+	}
+
+	sort.Slice(shared, func(i, j int) bool {
+		return TitleLess(shared[i].record, shared[j].record)
+	})
+
+	return shared
+}
+
 // CombineCollections combines two source Collections into a destination
-// Collection with a new name, leaving the two source Collections unmodified
-func (c *Catalog) CombineCollections(firstSrc, secondSrc *Collection, dstName string) Error {
+// Collection with a new name, leaving the two source Collections unmodified.
+// If either source is ordered, so is the destination, with the members of the
+// first source in their order followed by the rest of the second's. The
+// destination is created at a time.
+func (c *Catalog) CombineCollections(firstSrc, secondSrc *Collection, dstName string, at time.Time) Error {
 	if _, ok := c.collections[dstName]; ok {
 		return NewlineError(errDuplicateCollection)
 	}
 
-	dst := NewCollection(dstName)
-	c.collections[dstName] = dst
+	dst := NewCollection(dstName, at)
 
-	for _, record := range firstSrc.members {
-		_ = dst.AddMember(record)
+	if firstSrc.Ordered() || secondSrc.Ordered() {
+		dst.sortBy = sortByPosition
 	}
 
-	for _, record := range secondSrc.members {
-		_ = dst.AddMember(record)
+	c.insert(dst)
+
+	for _, record := range firstSrc.savedMembers() {
+		_ = dst.AddMember(record, at)
+	}
+
+	for _, record := range secondSrc.savedMembers() {
+		_ = dst.AddMember(record, at)
 	}
 
 	return nil
 }
 
-func (c *Catalog) String() string {
+// RenameCollection changes the name of a Collection at a time, keeping its
+// members
+func (c *Catalog) RenameCollection(collection *Collection, newName string, at time.Time) Error {
+	if _, ok := c.collections[newName]; ok {
+		return NewlineError(errDuplicateCollection)
+	}
+
+	c.remove(collection)
+	collection.name = newName
+	collection.touch(at)
+	c.insert(collection)
+
+	return nil
+}
+
+// CopyCollection creates a Collection with a new name and the same members,
+// description, owner and sort preference as a source Collection, leaving the
+// source Collection unmodified. The copy is created at a time.
+func (c *Catalog) CopyCollection(src *Collection, dstName string, at time.Time) Error {
+	if _, ok := c.collections[dstName]; ok {
+		return NewlineError(errDuplicateCollection)
+	}
+
+	dst := NewCollection(dstName, at)
+	dst.description = src.description
+	dst.owner = src.owner
+	dst.sortBy = src.sortBy
+	c.insert(dst)
+	_ = c.nest(dst, src.parent)
+
+	for _, record := range src.savedMembers() {
+		_ = dst.AddMember(record, at)
+	}
+
+	return nil
+}
+
+const errCollectionCycle = "Cannot nest a collection inside itself or its descendants!"
+
+// SetParent nests a Collection inside a parent Collection, or moves it to the
+// top of the Catalog if the parent is nil, at a time
+func (c *Catalog) SetParent(collection, parent *Collection, at time.Time) Error {
+	err := c.nest(collection, parent)
+
+	if err != nil {
+		return err
+	}
+
+	collection.touch(at)
+
+	return nil
+}
+
+// CollectionsContaining returns the Collections that contain a Record, either
+// as a member or through a Collection nested inside them, sorted by name in
+// ascending order
+func (c *Catalog) CollectionsContaining(record *Record) []*Collection {
+	var containing []*Collection
+
+	for _, collection := range c.sortedCollections() {
+		if collection.ContainsDeep(record) {
+			containing = append(containing, collection)
+		}
+	}
+
+	return containing
+}
+
+// withMember returns the Collections that have a Record as a member, or none
+// if there is no Catalog
+func (c *Catalog) withMember(record *Record) []*Collection {
+	if c == nil {
+		return nil
+	}
+
+	var containing []*Collection
+
+	for _, collection := range c.collections {
+		if _, ok := collection.members[record.id]; ok {
+			containing = append(containing, collection)
+		}
+	}
+
+	return containing
+}
+
+// Tree returns the Catalog as a tree, with each Collection under its parent and
+// indented by its depth, and siblings sorted by name in ascending order
+func (c *Catalog) Tree() string {
 	if len(c.collections) == 0 {
 		return "Catalog is empty"
 	}
 
-	var builder strings.Builder
-	builder.WriteString(fmt.Sprintf("Catalog contains %d collections:", len(c.collections)))
+	lines := []string{fmt.Sprintf(fmtCatalogHeader, len(c.collections))}
+
+	var visit func(collection *Collection, depth int)
+	visit = func(collection *Collection, depth int) {
+		noun := "members"
+
+		if len(collection.members) == 1 {
+			noun = "member"
+		}
+
+		lines = append(lines, fmt.Sprintf("%s%s (%d %s)",
+			strings.Repeat("  ", depth), collection.name, len(collection.members), noun))
+
+		for _, child := range collection.Children() {
+			visit(child, depth+1)
+		}
+	}
 
 	for _, collection := range c.sortedCollections() {
-		builder.WriteRune('\n')
-		builder.WriteString(collection.String())
+		if collection.parent == nil {
+			visit(collection, 0)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (c *Catalog) String() string {
+	if len(c.collections) == 0 {
+		return "Catalog is empty"
 	}
 
-	return builder.String()
+	return NewCatalogListing(c).Render(0, nil)
 }
 
 // Clear erases all Records from this Collection's set of members
@@ -204,18 +383,57 @@ func clearCollection(collection *Collection) {
 	}
 
 	collection.members = make(collectionMembers)
+	collection.inTitleOrder = NewRecordOrder(TitleLess)
+	collection.inRatingOrder = NewRecordOrder(RatingLess)
+	collection.inPosition = nil
 }
 
-func (c *Catalog) sortedCollections() []*Collection {
-	collectionSet := make([]*Collection, 0, len(c.collections))
+// nest moves a Collection from under its current parent to under a new one,
+// which may be nil. Returns an error if that would make a cycle.
+func (c *Catalog) nest(collection, parent *Collection) Error {
+	for ancestor := parent; ancestor != nil; ancestor = ancestor.parent {
+		if ancestor == collection {
+			return NewlineError(errCollectionCycle)
+		}
+	}
 
-	for _, collection := range c.collections {
-		collectionSet = append(collectionSet, collection)
+	if old := collection.parent; old != nil {
+		for i, child := range old.children {
+			if child == collection {
+				old.children = append(old.children[:i], old.children[i+1:]...)
+
+				break
+			}
+		}
 	}
 
-	sort.Slice(collectionSet, func(i, j int) bool {
-		return collectionSet[i].name < collectionSet[j].name
-	})
+	collection.parent = parent
+
+	if parent != nil {
+		parent.children = append(parent.children, collection)
+	}
+
+	return nil
+}
+
+// insert adds a Collection to the Catalog under its name
+func (c *Catalog) insert(collection *Collection) {
+	c.collections[collection.name] = collection
+	c.names = insertString(c.names, collection.name)
+}
+
+// remove takes a Collection out of the Catalog without touching its members
+func (c *Catalog) remove(collection *Collection) {
+	delete(c.collections, collection.name)
+	c.names = removeString(c.names, collection.name)
+}
+
+func (c *Catalog) sortedCollections() []*Collection {
+	collectionSet := make([]*Collection, len(c.names))
+
+	for i, name := range c.names {
+		collectionSet[i] = c.collections[name]
+	}
 
 	return collectionSet
 }
@@ -0,0 +1,130 @@
+// MIT License
+//
+// Copyright (c) 2019 Gregory Meyer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// articleLists are the leading articles of titles in some languages, which
+// -ignore-articles can leave out when titles are sorted
+var articleLists = map[string][]string{
+	"en": {"the", "a", "an"},
+	"fr": {"le", "la", "les", "l'", "un", "une", "des"},
+	"de": {"der", "die", "das", "ein", "eine"},
+	"es": {"el", "la", "los", "las", "un", "una", "unos", "unas"},
+	"it": {"il", "lo", "la", "i", "gli", "le", "l'", "un", "uno", "una"},
+}
+
+// ParseArticles reads a comma-separated list of the languages whose articles
+// titles are sorted without, such as "en,fr", into articles in lower case.
+// Other words in the list are articles themselves, so "en,den" adds "den" to
+// the English articles.
+func ParseArticles(text string) ([]string, error) {
+	var articles []string
+
+	for _, item := range strings.Split(text, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+
+		if list, ok := articleLists[item]; ok {
+			articles = append(articles, list...)
+		} else if item != "" && len(strings.Fields(item)) == 1 {
+			articles = append(articles, item)
+		} else {
+			return nil, fmt.Errorf("invalid article list %q, expected languages or words separated by commas", text)
+		}
+	}
+
+	return articles, nil
+}
+
+// Languages returns the languages that have built-in lists of articles
+func Languages() []string {
+	languages := make([]string, 0, len(articleLists))
+
+	for language := range articleLists {
+		languages = append(languages, language)
+	}
+
+	sort.Strings(languages)
+
+	return languages
+}
+
+// collationKey returns a key for a title such that ordering keys as strings
+// orders titles as a person would: leading articles left out, then case and
+// accents ignored, then accented letters after unaccented ones, then as the
+// titles themselves
+func collationKey(title string, articles []string) string {
+	stripped := stripArticle(title, articles)
+
+	var primary, secondary strings.Builder
+
+	for _, r := range stripped {
+		for _, d := range decompose(nil, r) {
+			d = unicode.ToLower(d)
+			secondary.WriteRune(d)
+
+			if combiningClasses[d] == 0 {
+				primary.WriteRune(d)
+			}
+		}
+	}
+
+	return primary.String() + "\x00" + secondary.String() + "\x00" + title
+}
+
+// collationPrefix returns the part of the collation key of every title that
+// starts with a prefix which comes before the titles themselves, so that
+// titles that sort at or after the prefix have keys not less than it. The
+// prefix is compared with titles after any articles they are sorted without.
+func collationPrefix(prefix string) string {
+	key := collationKey(prefix, nil)
+
+	return key[:strings.IndexByte(key, 0)]
+}
+
+// stripArticle leaves out the article a title starts with, if one of the
+// articles does and words follow it. Articles that end in an apostrophe, such
+// as "l'", are elided into the word that follows them, with either a straight
+// or a typographic apostrophe.
+func stripArticle(title string, articles []string) string {
+	for _, article := range articles {
+		spellings := []string{article + " "}
+
+		if elided := strings.TrimSuffix(article, "'"); elided != article {
+			spellings = []string{article, elided + "\u2019"}
+		}
+
+		for _, spelling := range spellings {
+			if len(title) > len(spelling) && strings.EqualFold(title[:len(spelling)], spelling) {
+				return title[len(spelling):]
+			}
+		}
+	}
+
+	return title
+}
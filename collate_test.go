@@ -0,0 +1,103 @@
+// MIT License
+//
+// Copyright (c) 2019 Gregory Meyer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestCollationKeyOrder(t *testing.T) {
+	articles, err := ParseArticles("en,fr")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// each title sorts before the next
+	titles := []string{
+		"Eclair",
+		"Éclair",
+		"L'Étranger",
+		"L’Étranger",
+		"the Godfather",
+		"Zorba",
+		"zorba",
+	}
+
+	for i := 1; i < len(titles); i++ {
+		if a, b := collationKey(titles[i-1], articles), collationKey(titles[i], articles); a >= b {
+			t.Errorf("%q sorts after %q", titles[i-1], titles[i])
+		}
+	}
+}
+
+func TestStripArticle(t *testing.T) {
+	articles := []string{"the", "l'"}
+
+	for _, test := range []struct {
+		title, want string
+	}{
+		{"The Godfather", "Godfather"},
+		{"Theory", "Theory"},
+		{"The", "The"},
+		{"L'Étranger", "Étranger"},
+		{"L’Étranger", "Étranger"},
+		{"Lost", "Lost"},
+	} {
+		if got := stripArticle(test.title, articles); got != test.want {
+			t.Errorf("stripArticle(%q) = %q, want %q", test.title, got, test.want)
+		}
+	}
+
+	if got := stripArticle("The Godfather", nil); got != "The Godfather" {
+		t.Errorf("stripArticle without articles = %q", got)
+	}
+}
+
+func TestRestoreSortsWithLibraryArticles(t *testing.T) {
+	session := NewSession()
+	session.library.SetArticles([]string{"the"})
+	contents := "version 2\nscale 1-5/1\nnext 3\n2\n1 DVD u The Zoo\n2 DVD u Yellow\n1\nfavorites 2\n1\n2\n"
+
+	if err := session.Restore(bufio.NewReader(strings.NewReader(contents))); err != nil {
+		t.Fatal(err)
+	}
+
+	var titles []string
+
+	for _, record := range session.library.sortedRecords() {
+		titles = append(titles, record.Title())
+	}
+
+	if strings.Join(titles, ",") != "Yellow,The Zoo" {
+		t.Errorf("Library sorted as %v, want Yellow before The Zoo", titles)
+	}
+
+	collection, _ := session.catalog.FindCollection("favorites")
+
+	if members := collection.sortedMembers(); members[0].Title() != "Yellow" {
+		t.Errorf("Collection sorted with %s first, want Yellow", members[0].Title())
+	}
+}
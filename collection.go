@@ -26,24 +26,81 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"math/rand"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type collectionMembers map[int]*Record
 
-// Collection is a named set of Records
+// Collection is a named set of Records, kept in order of title and of rating,
+// along with a description of what it is for, who owns it, and when it was created and last
+// modified. An ordered Collection also keeps its members in an order of its
+// own, such as a watch order or a ranking.
 type Collection struct {
-	name    string
-	members collectionMembers
+	name          string
+	members       collectionMembers
+	inTitleOrder  *RecordOrder
+	inRatingOrder *RecordOrder
+
+	// inPosition is the order of the members of an ordered Collection
+	inPosition []*Record
+
+	// parent is the Collection this one is nested inside, or nil if it is at
+	// the top of the Catalog
+	parent   *Collection
+	children []*Collection
+
+	// parentName is the name of the parent read by RestoreCollection, until
+	// RestoreCatalog finds the parent itself
+	parentName string
+
+	description string
+	owner       string
+	created     time.Time
+	modified    time.Time
+
+	// sortBy is how members are listed: sortByTitle, sortByRating, or
+	// sortByPosition for an ordered Collection
+	sortBy string
 }
 
-// NewCollection creates a Collection
-func NewCollection(name string) *Collection {
-	return &Collection{name, make(collectionMembers)}
+const (
+	sortByTitle    = "title"
+	sortByRating   = "rating"
+	sortByPosition = "position"
+)
+
+func isSortBy(sortBy string) bool {
+	return sortBy == sortByTitle || sortBy == sortByRating || sortBy == sortByPosition
 }
 
-// RestoreCollection deserializes a Collection from a *bufio.Reader
-func RestoreCollection(reader *bufio.Reader, library *Library) (*Collection, Error) {
+const errNotOrdered = "Collection is not ordered!"
+const errNotMember = "Record is not a member in the collection!"
+const errNoSuchPosition = "No position with that number in the collection!"
+
+// fmtCollectionTime is how a Collection's timestamps are printed
+const fmtCollectionTime = "2006-01-02 15:04"
+
+// NewCollection creates a Collection, created and last modified at a time
+func NewCollection(name string, at time.Time) *Collection {
+	return &Collection{
+		name:          name,
+		members:       make(collectionMembers),
+		inTitleOrder:  NewRecordOrder(TitleLess),
+		inRatingOrder: NewRecordOrder(RatingLess),
+		created:       at,
+		modified:      at,
+		sortBy:        sortByTitle,
+	}
+}
+
+// RestoreCollection deserializes a Collection of Records in a Library from a
+// *bufio.Reader, in a file of a save format version. Files saved before
+// saveFormatVersion name members by title instead of by ID.
+func RestoreCollection(reader *bufio.Reader, library *Library, version int) (*Collection, Error) {
 	name := ReadWord(reader)
 
 	// EOF
@@ -51,21 +108,26 @@ func RestoreCollection(reader *bufio.Reader, library *Library) (*Collection, Err
 		return nil, NewlineError(ErrInvalidFile)
 	}
 
-	collection := NewCollection(name)
+	collection := NewCollection(name, time.Time{})
 	numMembers, err := ReadInt(reader)
 
 	if err != nil || numMembers < 0 {
 		return nil, NewlineError(ErrInvalidFile)
 	}
 
-	ReadLine(reader)
+	// files saved before Collections had metadata end the line here, so their
+	// timestamps are unknown
+	if !collection.restoreAttributes(ReadLine(reader)) {
+		return nil, NewlineError(ErrInvalidFile)
+	}
+
+	members := make([]*Record, 0, numMembers)
 
 	for i := 0; i < numMembers; i++ {
-		title := ReadLine(reader)
-		record, ok := library.byTitle[title]
+		record, err := restoreMember(reader, library, version)
 
-		if !ok {
-			return nil, NewlineError(ErrInvalidFile)
+		if err != nil {
+			return nil, err
 		}
 
 		if _, ok := collection.members[record.id]; ok {
@@ -73,73 +135,516 @@ func RestoreCollection(reader *bufio.Reader, library *Library) (*Collection, Err
 		}
 
 		collection.members[record.id] = record
+		members = append(members, record)
+	}
+
+	// the members are only counted once the whole Collection is read, and
+	// sorted once rather than inserted in order one by one
+	for _, record := range members {
 		record.numCollections++
 	}
 
+	collection.inTitleOrder = newSortedRecordOrder(members, TitleLess)
+	collection.inRatingOrder = newSortedRecordOrder(members, RatingLess)
+
+	if collection.Ordered() {
+		collection.inPosition = members
+	}
+
 	return collection, nil
 }
 
-// AddMember inserts a Record into this Collection's set of members
-func (c *Collection) AddMember(record *Record) Error {
+// AddMember inserts a Record into this Collection's set of members at a time
+func (c *Collection) AddMember(record *Record, at time.Time) Error {
 	if _, ok := c.members[record.id]; ok {
 		return NewlineError("Record is already a member in the collection!")
 	}
 
-	c.members[record.id] = record
-	record.numCollections++
+	c.insert(record, len(c.inPosition))
+	c.touch(at)
+
+	return nil
+}
+
+// InsertMember inserts a Record into this ordered Collection's set of members
+// at a position, counting from 1, at a time
+func (c *Collection) InsertMember(record *Record, position int, at time.Time) Error {
+	if !c.Ordered() {
+		return NewlineError(errNotOrdered)
+	}
+
+	if _, ok := c.members[record.id]; ok {
+		return NewlineError("Record is already a member in the collection!")
+	}
+
+	if position < 1 || position > len(c.inPosition)+1 {
+		return NewlineError(errNoSuchPosition)
+	}
+
+	c.insert(record, position-1)
+	c.touch(at)
 
 	return nil
 }
 
-// DeleteMember erases a Record from this Collection's set of members
-func (c *Collection) DeleteMember(record *Record) Error {
+// MoveMember moves a member of this ordered Collection to a position,
+// counting from 1, at a time
+func (c *Collection) MoveMember(record *Record, position int, at time.Time) Error {
+	if !c.Ordered() {
+		return NewlineError(errNotOrdered)
+	}
+
 	if _, ok := c.members[record.id]; !ok {
-		return NewlineError("Record is not a member in the collection!")
+		return NewlineError(errNotMember)
 	}
 
-	delete(c.members, record.id)
-	record.numCollections--
+	if position < 1 || position > len(c.inPosition) {
+		return NewlineError(errNoSuchPosition)
+	}
+
+	c.remove(record)
+	c.insert(record, position-1)
+	c.touch(at)
 
 	return nil
 }
 
-// Save serializes a Collection to an io.Writer in a format suitable for recovery
+// Reverse reverses the order of this ordered Collection at a time
+func (c *Collection) Reverse(at time.Time) Error {
+	if !c.Ordered() {
+		return NewlineError(errNotOrdered)
+	}
+
+	for i, j := 0, len(c.inPosition)-1; i < j; i, j = i+1, j-1 {
+		c.inPosition[i], c.inPosition[j] = c.inPosition[j], c.inPosition[i]
+	}
+
+	c.touch(at)
+
+	return nil
+}
+
+// Shuffle puts the members of this ordered Collection in a random order drawn
+// from a seed at a time, so that shuffling again with the same seed gives the
+// same order
+func (c *Collection) Shuffle(seed int64, at time.Time) Error {
+	if !c.Ordered() {
+		return NewlineError(errNotOrdered)
+	}
+
+	rand.New(rand.NewSource(seed)).Shuffle(len(c.inPosition), func(i, j int) {
+		c.inPosition[i], c.inPosition[j] = c.inPosition[j], c.inPosition[i]
+	})
+
+	c.touch(at)
+
+	return nil
+}
+
+// Ordered returns true if this Collection keeps its members in an order of
+// its own
+func (c *Collection) Ordered() bool {
+	return c.sortBy == sortByPosition
+}
+
+// DeleteMember erases a Record from this Collection's set of members at a time
+func (c *Collection) DeleteMember(record *Record, at time.Time) Error {
+	if _, ok := c.members[record.id]; !ok {
+		return NewlineError(errNotMember)
+	}
+
+	c.remove(record)
+	c.touch(at)
+
+	return nil
+}
+
+// SetDescription changes what this Collection says it is for at a time. An
+// empty description removes it.
+func (c *Collection) SetDescription(description string, at time.Time) {
+	c.description = description
+	c.touch(at)
+}
+
+// SetOwner changes who owns this Collection at a time. An empty owner removes
+// it.
+func (c *Collection) SetOwner(owner string, at time.Time) {
+	c.owner = owner
+	c.touch(at)
+}
+
+// SetSortBy changes how the members of this Collection are listed: by
+// sortByTitle, by sortByRating, or by sortByPosition to make it an ordered
+// Collection whose order starts out as the order it was listed in. It changes
+// at a time.
+func (c *Collection) SetSortBy(sortBy string, at time.Time) Error {
+	if !isSortBy(sortBy) {
+		return NewlineError("Collections can only be sorted by title, rating or position!")
+	}
+
+	if sortBy == sortByPosition && !c.Ordered() {
+		c.inPosition = append([]*Record(nil), c.order().Records()...)
+	} else if sortBy != sortByPosition {
+		c.inPosition = nil
+	}
+
+	c.sortBy = sortBy
+	c.touch(at)
+
+	return nil
+}
+
+// Save serializes a Collection to an io.Writer in a format suitable for
+// recovery, with a line for the ID of each member
 func (c *Collection) Save(writer io.Writer) {
-	FprintfOrPanic(writer, "%s %d\n", c.name, len(c.members))
+	FprintfOrPanic(writer, "%s %d%s\n", c.name, len(c.members), c.attributes())
 
-	for _, record := range c.sortedMembers() {
-		FprintfOrPanic(writer, "%s\n", record.title)
+	for _, record := range c.savedMembers() {
+		FprintfOrPanic(writer, "%d\n", record.id)
 	}
 }
 
+// restoreMember reads the line of a member of a Collection written by Save, or
+// by an older version that wrote the title of the one Record that had it
+func restoreMember(reader *bufio.Reader, library *Library, version int) (*Record, Error) {
+	if version < saveFormatVersion {
+		records := library.byTitle[readTitle(reader)]
+
+		if len(records) != 1 {
+			return nil, NewlineError(ErrInvalidFile)
+		}
+
+		return records[0], nil
+	}
+
+	id, err := ReadInt(reader)
+
+	if err != nil {
+		return nil, NewlineError(ErrInvalidFile)
+	}
+
+	record, ok := library.byID[id]
+
+	if !ok {
+		return nil, NewlineError(ErrInvalidFile)
+	}
+
+	return record, nil
+}
+
 // Name returns the name of this Collection
 func (c *Collection) Name() string {
 	return c.name
 }
 
 func (c *Collection) String() string {
-	var builder strings.Builder
+	return c.render(nil)
+}
 
-	builder.WriteString(fmt.Sprintf("Collection %s contains:", c.name))
+// Details returns a line for each piece of metadata about this Collection that
+// is known
+func (c *Collection) Details() []string {
+	var lines []string
 
+	if c.description != "" {
+		lines = append(lines, "Description: "+c.description)
+	}
+
+	if c.owner != "" {
+		lines = append(lines, "Owner: "+c.owner)
+	}
+
+	if c.sortBy != sortByTitle {
+		lines = append(lines, "Sorted by "+c.sortBy)
+	}
+
+	if c.parent != nil {
+		lines = append(lines, "Parent: "+c.parent.name)
+	}
+
+	if len(c.children) > 0 {
+		names := make([]string, len(c.children))
+
+		for i, child := range c.Children() {
+			names[i] = child.name
+		}
+
+		lines = append(lines, "Children: "+strings.Join(names, ", "))
+	}
+
+	if !c.created.IsZero() {
+		lines = append(lines, fmt.Sprintf("Created %s, modified %s",
+			c.created.Format(fmtCollectionTime), c.modified.Format(fmtCollectionTime)))
+	}
+
+	return lines
+}
+
+// render prints a Collection like String, as rows of a Table if it is not nil
+func (c *Collection) render(table *Table) string {
 	if len(c.members) == 0 {
-		builder.WriteString(" None")
-	} else {
-		builder.WriteRune('\n')
-		builder.WriteString(SprintRecords(c.sortedMembers()))
+		return fmt.Sprintf(fmtCollectionHeader+" None", c.name)
 	}
 
-	return builder.String()
+	return NewCollectionListing(c).Render(0, table)
 }
 
-func (c *Collection) sortedMembers() []*Record {
-	memberSet := make([]*Record, 0, len(c.members))
+// Children returns the Collections nested directly inside this one, sorted by
+// name in ascending order
+func (c *Collection) Children() []*Collection {
+	children := append([]*Collection(nil), c.children...)
+
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].name < children[j].name
+	})
+
+	return children
+}
+
+// ContainsDeep returns true if a Record is a member of this Collection or of
+// any Collection nested inside it
+func (c *Collection) ContainsDeep(record *Record) bool {
+	if _, ok := c.members[record.id]; ok {
+		return true
+	}
+
+	for _, child := range c.children {
+		if child.ContainsDeep(record) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DeepMembers returns the Records that are members of this Collection or of
+// any Collection nested inside it, sorted by title in ascending order
+func (c *Collection) DeepMembers() []*Record {
+	members := make(collectionMembers)
+	c.collectDeepMembers(members)
+
+	records := make([]*Record, 0, len(members))
+
+	for _, record := range members {
+		records = append(records, record)
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return TitleLess(records[i], records[j])
+	})
+
+	return records
+}
+
+func (c *Collection) collectDeepMembers(members collectionMembers) {
+	for id, record := range c.members {
+		members[id] = record
+	}
+
+	for _, child := range c.children {
+		child.collectDeepMembers(members)
+	}
+}
+
+// order returns the members in the order they are listed in
+func (c *Collection) order() *RecordOrder {
+	switch c.sortBy {
+	case sortByRating:
+		return c.inRatingOrder
+	case sortByPosition:
+		return newFixedRecordOrder(c.inPosition)
+	}
+
+	return c.inTitleOrder
+}
+
+// savedMembers returns the members in the order they are saved in: by
+// position for an ordered Collection, otherwise by title. The slice must not
+// be modified.
+func (c *Collection) savedMembers() []*Record {
+	if c.Ordered() {
+		return c.inPosition
+	}
+
+	return c.sortedMembers()
+}
+
+// insert adds a Record to the members, at an index of the order of an ordered
+// Collection
+func (c *Collection) insert(record *Record, index int) {
+	c.members[record.id] = record
+	c.inTitleOrder.Insert(record)
+	c.inRatingOrder.Insert(record)
+	record.numCollections++
+
+	if c.Ordered() {
+		c.inPosition = append(c.inPosition, nil)
+		copy(c.inPosition[index+1:], c.inPosition[index:])
+		c.inPosition[index] = record
+	}
+}
+
+// remove takes a Record out of the members
+func (c *Collection) remove(record *Record) {
+	delete(c.members, record.id)
+	c.inTitleOrder.Remove(record)
+	c.inRatingOrder.Remove(record)
+	record.numCollections--
+
+	for i, member := range c.inPosition {
+		if member == record {
+			c.inPosition = append(c.inPosition[:i], c.inPosition[i+1:]...)
+
+			break
+		}
+	}
+}
+
+// touch marks this Collection as modified at a time
+func (c *Collection) touch(at time.Time) {
+	c.modified = at
+}
+
+// attributes returns the metadata of this Collection that differs from a new
+// Collection's, as space-separated key=value pairs with a leading space
+func (c *Collection) attributes() string {
+	var attributes strings.Builder
+
+	if !c.created.IsZero() {
+		fmt.Fprintf(&attributes, " created=%s modified=%s",
+			c.created.Format(time.RFC3339), c.modified.Format(time.RFC3339))
+	}
+
+	if c.description != "" {
+		fmt.Fprintf(&attributes, " description=%s", strconv.Quote(c.description))
+	}
+
+	if c.owner != "" {
+		fmt.Fprintf(&attributes, " owner=%s", strconv.Quote(c.owner))
+	}
+
+	if c.sortBy != sortByTitle {
+		fmt.Fprintf(&attributes, " sort=%s", c.sortBy)
+	}
 
-	for _, record := range c.members {
-		memberSet = append(memberSet, record)
+	if c.parent != nil {
+		fmt.Fprintf(&attributes, " parent=%s", strconv.Quote(c.parent.name))
 	}
 
-	SortRecordsByTitle(memberSet)
+	return attributes.String()
+}
+
+// restoreAttributes sets the metadata of this Collection from the key=value
+// pairs written by attributes. Keys it doesn't know are ignored. Returns false
+// if the pairs are malformed.
+func (c *Collection) restoreAttributes(line string) bool {
+	c.created = time.Time{}
+	c.modified = time.Time{}
+
+	attributes, ok := parseAttributes(line)
+
+	if !ok {
+		return false
+	}
+
+	for key, value := range attributes {
+		var err error
+
+		switch key {
+		case "created":
+			c.created, err = time.Parse(time.RFC3339, value)
+		case "modified":
+			c.modified, err = time.Parse(time.RFC3339, value)
+		case "description":
+			c.description = value
+		case "owner":
+			c.owner = value
+		case "parent":
+			c.parentName = value
+		case "sort":
+			if !isSortBy(value) {
+				return false
+			}
+
+			c.sortBy = value
+		}
+
+		if err != nil {
+			return false
+		}
+	}
+
+	return c.created.IsZero() == c.modified.IsZero()
+}
 
-	return memberSet
+// parseAttributes splits a line of space-separated key=value pairs, where each
+// value is either a word or a Go string literal. Returns false if the line is
+// malformed.
+func parseAttributes(line string) (map[string]string, bool) {
+	attributes := make(map[string]string)
+
+	for {
+		line = strings.TrimLeft(line, " \t\r")
+
+		if line == "" {
+			return attributes, true
+		}
+
+		equals := strings.IndexByte(line, '=')
+
+		if equals <= 0 || strings.ContainsAny(line[:equals], " \t\"") {
+			return nil, false
+		}
+
+		key := line[:equals]
+		line = line[equals+1:]
+
+		var value string
+
+		if strings.HasPrefix(line, "\"") {
+			end := quotedLength(line)
+
+			if end < 0 {
+				return nil, false
+			}
+
+			unquoted, err := strconv.Unquote(line[:end])
+
+			if err != nil {
+				return nil, false
+			}
+
+			value = unquoted
+			line = line[end:]
+		} else if space := strings.IndexAny(line, " \t\r"); space >= 0 {
+			value = line[:space]
+			line = line[space:]
+		} else {
+			value = line
+			line = ""
+		}
+
+		attributes[key] = value
+	}
+}
+
+// quotedLength returns the length of the double-quoted string literal at the
+// start of s, or -1 if it isn't terminated
+func quotedLength(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+
+	return -1
+}
+
+// sortedMembers returns every member in order of title. The slice must not be
+// modified.
+func (c *Collection) sortedMembers() []*Record {
+	return c.inTitleOrder.Records()
 }
@@ -0,0 +1,92 @@
+// MIT License
+//
+// Copyright (c) 2019 Gregory Meyer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRestoreLegacyMembersByExactTitle(t *testing.T) {
+	const records = "3\n1 DVD 4 Alien\n2 VHS 4 Alien\n3 VHS 0 Showboat\n"
+
+	for _, test := range []struct {
+		member string
+		ok     bool
+	}{
+		{"Showboat", true},
+		{"Alien", false},
+		{"Alien [#1]", false},
+		{"Showboat [VHS]", false},
+	} {
+		session := NewSession()
+		contents := records + "1\nfavorites 1\n" + test.member + "\n"
+		err := session.Restore(bufio.NewReader(strings.NewReader(contents)))
+
+		if ok := err == nil; ok != test.ok {
+			t.Errorf("restoring a member %q: error %v, want success %v", test.member, err, test.ok)
+		}
+	}
+}
+
+func TestCollectionKeepsRatingOrder(t *testing.T) {
+	session, dispatcher := NewSession(), NewCommandDispatcher()
+
+	for _, line := range []string{
+		"ar DVD Alien", "ar VHS Brazil", "ar CD Casablanca", "ac favorites",
+		"am favorites 1", "am favorites 3", "ms favorites rating",
+	} {
+		runCommand(t, session, dispatcher, line)
+	}
+
+	collection := session.catalog.collections["favorites"]
+
+	for _, test := range []struct {
+		line string
+		want []string
+	}{
+		{"mr 3 4", []string{"Casablanca", "Alien"}},
+		{"mr 1 4.5", []string{"Alien", "Casablanca"}},
+		{"mr 3 4.5", []string{"Alien", "Casablanca"}},
+		{"mt 3 Abyss", []string{"Abyss", "Alien"}},
+		{"mr 3 3", []string{"Alien", "Abyss"}},
+		{"rs 1-2/1", []string{"Abyss", "Alien"}},
+		{"am favorites 2", []string{"Abyss", "Alien", "Brazil"}},
+		{"mr 2 2", []string{"Abyss", "Alien", "Brazil"}},
+		{"mt 2 Aardvark", []string{"Aardvark", "Abyss", "Alien"}},
+	} {
+		runCommand(t, session, dispatcher, test.line)
+
+		var titles []string
+
+		for _, record := range collection.order().Records() {
+			titles = append(titles, record.title)
+		}
+
+		if !reflect.DeepEqual(titles, test.want) {
+			t.Errorf("after %s, favorites listed %q, want %q", test.line, titles, test.want)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+// MIT License
+//
+// Copyright (c) 2019 Gregory Meyer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+// Database is an embedded key-value store kept in a single append-only file.
+// Every change is a batch of puts and deletes appended to the file as one
+// checksummed frame, so a batch is either entirely on disk or entirely absent
+// after a crash. The whole key space is kept in memory.
+type Database struct {
+	filename string
+	file     *os.File
+	values   map[string][]byte
+	size     int64 // size of the file in bytes
+	live     int64 // bytes of the file a compacted copy would need
+}
+
+const (
+	databaseMagic = "mediamanager db 1\n"
+
+	opPut    = 'P'
+	opDelete = 'D'
+
+	// compaction waits for the file to grow past this size
+	minCompactionSize = 1 << 16
+)
+
+var errCorruptDatabase = errors.New("corrupt database")
+
+// Batch is a set of changes to a Database that is written all at once
+type Batch struct {
+	payload bytes.Buffer
+	puts    map[string][]byte
+	deletes map[string]bool
+}
+
+// NewBatch creates an empty Batch
+func NewBatch() *Batch {
+	return &Batch{puts: make(map[string][]byte), deletes: make(map[string]bool)}
+}
+
+// Put sets a key to a value
+func (b *Batch) Put(key string, value []byte) {
+	b.payload.WriteByte(opPut)
+	writeBytes(&b.payload, []byte(key))
+	writeBytes(&b.payload, value)
+	b.puts[key] = value
+	delete(b.deletes, key)
+}
+
+// Delete removes a key
+func (b *Batch) Delete(key string) {
+	b.payload.WriteByte(opDelete)
+	writeBytes(&b.payload, []byte(key))
+	b.deletes[key] = true
+	delete(b.puts, key)
+}
+
+// Empty returns true if this Batch makes no changes
+func (b *Batch) Empty() bool {
+	return b.payload.Len() == 0
+}
+
+// OpenDatabase opens or creates a Database file. A partially written batch at
+// the end of the file is discarded.
+func OpenDatabase(filename string) (*Database, error) {
+	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0644)
+
+	if err != nil {
+		return nil, err
+	}
+
+	db := &Database{filename, file, make(map[string][]byte), 0, 0}
+
+	if err := db.load(); err != nil {
+		_ = file.Close()
+
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// Get returns the value of a key, or false if there is none
+func (db *Database) Get(key string) ([]byte, bool) {
+	value, ok := db.values[key]
+
+	return value, ok
+}
+
+// Keys returns all keys in ascending order
+func (db *Database) Keys() []string {
+	keys := make([]string, 0, len(db.values))
+
+	for key := range db.values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Write appends a Batch to the Database file and applies it, then compacts the
+// file if most of it is no longer needed
+func (db *Database) Write(batch *Batch) error {
+	if batch.Empty() {
+		return nil
+	}
+
+	frame := encodeFrame(batch.payload.Bytes())
+
+	if _, err := db.file.Write(frame); err != nil {
+		return db.truncate(err)
+	}
+
+	if err := db.file.Sync(); err != nil {
+		return db.truncate(err)
+	}
+
+	db.size += int64(len(frame))
+
+	for key := range batch.deletes {
+		if value, ok := db.values[key]; ok {
+			db.live -= entrySize(key, value)
+			delete(db.values, key)
+		}
+	}
+
+	for key, value := range batch.puts {
+		if old, ok := db.values[key]; ok {
+			db.live -= entrySize(key, old)
+		}
+
+		db.values[key] = value
+		db.live += entrySize(key, value)
+	}
+
+	if db.size > minCompactionSize && db.size > 2*db.live {
+		return db.compact()
+	}
+
+	return nil
+}
+
+// truncate cuts off whatever part of a frame a failed Write left at the end of
+// the file, so that later frames aren't appended after it and lost when the
+// file is next loaded. Returns the error the Write failed with.
+func (db *Database) truncate(err error) error {
+	_ = db.file.Truncate(db.size)
+	_, _ = db.file.Seek(db.size, io.SeekStart)
+
+	return err
+}
+
+// Close closes the Database file
+func (db *Database) Close() error {
+	return db.file.Close()
+}
+
+// load reads every complete batch in the file, then truncates anything after
+// them
+func (db *Database) load() error {
+	contents, err := ioutil.ReadAll(db.file)
+
+	if err != nil {
+		return err
+	}
+
+	if len(contents) == 0 {
+		return db.compact()
+	}
+
+	if !bytes.HasPrefix(contents, []byte(databaseMagic)) {
+		return errCorruptDatabase
+	}
+
+	offset := len(databaseMagic)
+
+	for offset < len(contents) {
+		payload, n := decodeFrame(contents[offset:])
+
+		if n == 0 {
+			break
+		}
+
+		if err := db.apply(payload); err != nil {
+			return err
+		}
+
+		offset += n
+	}
+
+	db.size = int64(offset)
+
+	for key, value := range db.values {
+		db.live += entrySize(key, value)
+	}
+
+	if offset < len(contents) {
+		if err := db.file.Truncate(int64(offset)); err != nil {
+			return err
+		}
+	}
+
+	_, err = db.file.Seek(int64(offset), io.SeekStart)
+
+	return err
+}
+
+func (db *Database) apply(payload []byte) error {
+	reader := bytes.NewReader(payload)
+
+	for reader.Len() > 0 {
+		op, _ := reader.ReadByte()
+		key, err := readBytes(reader)
+
+		if err != nil {
+			return err
+		}
+
+		switch op {
+		case opPut:
+			value, err := readBytes(reader)
+
+			if err != nil {
+				return err
+			}
+
+			db.values[string(key)] = value
+		case opDelete:
+			delete(db.values, string(key))
+		default:
+			return errCorruptDatabase
+		}
+	}
+
+	return nil
+}
+
+// compact rewrites the file as a single batch of the current values
+func (db *Database) compact() error {
+	batch := NewBatch()
+
+	for _, key := range db.Keys() {
+		batch.Put(key, db.values[key])
+	}
+
+	var contents bytes.Buffer
+	contents.WriteString(databaseMagic)
+
+	if !batch.Empty() {
+		contents.Write(encodeFrame(batch.payload.Bytes()))
+	}
+
+	if err := writeFileAtomically(db.filename, contents.Bytes()); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(db.filename, os.O_RDWR|os.O_APPEND, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	_ = db.file.Close()
+	db.file = file
+	db.size = int64(contents.Len())
+
+	return nil
+}
+
+// a frame is the length of its payload, the CRC-32 of its payload, then the
+// payload itself
+const frameHeaderSize = 8
+
+func encodeFrame(payload []byte) []byte {
+	frame := make([]byte, frameHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(frame[4:8], crc32.ChecksumIEEE(payload))
+	copy(frame[frameHeaderSize:], payload)
+
+	return frame
+}
+
+// decodeFrame returns the payload of the frame at the start of data and the
+// size of the frame, or a size of 0 if the frame is incomplete or corrupt
+func decodeFrame(data []byte) ([]byte, int) {
+	if len(data) < frameHeaderSize {
+		return nil, 0
+	}
+
+	length := int(binary.LittleEndian.Uint32(data[0:4]))
+
+	if length > len(data)-frameHeaderSize {
+		return nil, 0
+	}
+
+	payload := data[frameHeaderSize : frameHeaderSize+length]
+
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(data[4:8]) {
+		return nil, 0
+	}
+
+	return payload, frameHeaderSize + length
+}
+
+func writeBytes(buffer *bytes.Buffer, data []byte) {
+	var length [binary.MaxVarintLen64]byte
+	buffer.Write(length[:binary.PutUvarint(length[:], uint64(len(data)))])
+	buffer.Write(data)
+}
+
+func readBytes(reader *bytes.Reader) ([]byte, error) {
+	length, err := binary.ReadUvarint(reader)
+
+	if err != nil || length > uint64(reader.Len()) {
+		return nil, errCorruptDatabase
+	}
+
+	data := make([]byte, length)
+	_, _ = reader.Read(data)
+
+	return data, nil
+}
+
+// entrySize estimates the bytes a key and value take up in a compacted file
+func entrySize(key string, value []byte) int64 {
+	return int64(1 + 2*binary.MaxVarintLen64 + len(key) + len(value))
+}
@@ -0,0 +1,91 @@
+// MIT License
+//
+// Copyright (c) 2019 Gregory Meyer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDatabaseWriteAfterFailedWriteIsKept(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "test.db")
+
+	db, err := OpenDatabase(filename)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := NewBatch()
+	first.Put("a", []byte("1"))
+
+	if err := db.Write(first); err != nil {
+		t.Fatal(err)
+	}
+
+	// a Write that fails partway leaves part of its frame at the end of the
+	// file
+	failed := NewBatch()
+	failed.Put("b", []byte("2"))
+	frame := encodeFrame(failed.payload.Bytes())
+
+	if _, err := db.file.Write(frame[:len(frame)/2]); err != nil {
+		t.Fatal(err)
+	}
+
+	failure := errors.New("disk full")
+
+	if err := db.truncate(failure); err != failure {
+		t.Errorf("truncate returned %v, want the error of the Write", err)
+	}
+
+	second := NewBatch()
+	second.Put("c", []byte("3"))
+
+	if err := db.Write(second); err != nil {
+		t.Fatal(err)
+	}
+
+	_ = db.Close()
+	db, err = OpenDatabase(filename)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	for key, want := range map[string]string{"a": "1", "c": "3"} {
+		if value, ok := db.Get(key); !ok || string(value) != want {
+			t.Errorf("%s = %q after reopening, want %q", key, value, want)
+		}
+	}
+
+	if _, ok := db.Get("b"); ok {
+		t.Errorf("the failed batch was kept")
+	}
+}
@@ -0,0 +1,180 @@
+// MIT License
+//
+// Copyright (c) 2019 Gregory Meyer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const diffContext = 3
+
+type diffOp struct {
+	kind byte // ' ' for a shared line, '-' for a removed line, '+' for an added line
+	line string
+}
+
+// UnifiedDiff returns the differences between two sequences of lines in
+// unified diff format, or an empty string if they are equal
+func UnifiedDiff(fromName, toName string, from, to []string) string {
+	ops := diffLines(from, to)
+
+	// fromPos[k] and toPos[k] count the lines of each side consumed before ops[k]
+	fromPos := make([]int, len(ops)+1)
+	toPos := make([]int, len(ops)+1)
+
+	for k, op := range ops {
+		fromPos[k+1], toPos[k+1] = fromPos[k], toPos[k]
+
+		if op.kind != '+' {
+			fromPos[k+1]++
+		}
+
+		if op.kind != '-' {
+			toPos[k+1]++
+		}
+	}
+
+	var builder strings.Builder
+
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		if builder.Len() == 0 {
+			builder.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", fromName, toName))
+		}
+
+		// a hunk keeps going until the changes are separated by enough shared
+		// lines that their contexts would not overlap
+		end := i + 1
+
+		for j := end; j < len(ops) && j-end <= 2*diffContext; j++ {
+			if ops[j].kind != ' ' {
+				end = j + 1
+			}
+		}
+
+		start := maxInt(0, i-diffContext)
+		stop := minInt(len(ops), end+diffContext)
+
+		builder.WriteString(fmt.Sprintf("@@ -%s +%s @@\n",
+			hunkRange(fromPos[start], fromPos[stop]), hunkRange(toPos[start], toPos[stop])))
+
+		for _, op := range ops[start:stop] {
+			builder.WriteByte(op.kind)
+			builder.WriteString(op.line)
+			builder.WriteRune('\n')
+		}
+
+		i = stop
+	}
+
+	return builder.String()
+}
+
+func hunkRange(start, stop int) string {
+	count := stop - start
+
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	} else if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// diffLines computes a shortest edit script between two sequences of lines
+// from their longest common subsequence
+func diffLines(from, to []string) []diffOp {
+	prefix := 0
+
+	for prefix < len(from) && prefix < len(to) && from[prefix] == to[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+
+	for suffix < len(from)-prefix && suffix < len(to)-prefix &&
+		from[len(from)-1-suffix] == to[len(to)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]diffOp, 0, len(from)+len(to))
+
+	for _, line := range from[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+
+	a, b := from[prefix:len(from)-suffix], to[prefix:len(to)-suffix]
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = maxInt(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+
+	for i < len(a) && j < len(b) {
+		if a[i] == b[j] {
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		} else if lcs[i+1][j] >= lcs[i][j+1] {
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		} else {
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+
+	for _, line := range from[len(from)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+
+	return ops
+}
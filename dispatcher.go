@@ -0,0 +1,480 @@
+// MIT License
+//
+// Copyright (c) 2019 Gregory Meyer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Args reads the arguments of a Command from an input stream as they are
+// needed, so a Command that fails early leaves the rest of its line unread
+type Args struct {
+	reader   *bufio.Reader
+	consumed []string
+
+	// journaled is true if the arguments are replayed from a Journal, which
+	// records the times Commands happened at
+	journaled bool
+}
+
+// NewArgs creates Args that read from an io.Reader
+func NewArgs(reader io.Reader) *Args {
+	if buffered, ok := reader.(*bufio.Reader); ok {
+		return &Args{buffered, nil, false}
+	}
+
+	return &Args{bufio.NewReader(reader), nil, false}
+}
+
+// newJournaledArgs creates Args that read an entry of a Journal
+func newJournaledArgs(entry string) *Args {
+	args := NewArgs(strings.NewReader(entry))
+	args.journaled = true
+
+	return args
+}
+
+// Word reads the next whitespace-delimited word
+func (a *Args) Word() string {
+	word := ReadWord(a.reader)
+	a.consumed = append(a.consumed, word)
+
+	return word
+}
+
+// Int reads the next integer
+func (a *Args) Int() (int, Error) {
+	value, err := ReadInt(a.reader)
+
+	if err != nil {
+		return 0, err
+	}
+
+	a.consumed = append(a.consumed, strconv.Itoa(value))
+
+	return value, nil
+}
+
+// Line reads the rest of the current line
+func (a *Args) Line() string {
+	return ReadLine(a.reader)
+}
+
+// Title reads the rest of the current line as a title, collapsing runs of
+// whitespace between words into a single space and normalizing it as
+// normalizeTitle does
+func (a *Args) Title() (string, Error) {
+	fields := strings.Fields(a.Line())
+
+	if len(fields) == 0 {
+		return "", RegularError("Could not read a title!")
+	}
+
+	title := normalizeTitle(strings.Join(fields, " "))
+	a.consumed = append(a.consumed, title)
+
+	return title, nil
+}
+
+// Qualify replaces the title last read by Title with the title of a Record
+// qualified as Library.QualifiedTitle does, so the arguments read back as the
+// same Record even if it was chosen from several with the title
+func (a *Args) Qualify(library *Library, record *Record) {
+	a.consumed[len(a.consumed)-1] = library.QualifiedTitle(record)
+}
+
+// Rating reads a Rating, such as 4.5, or "u" for NoRating
+func (a *Args) Rating() (Rating, Error) {
+	rating, err := ParseRating(ReadWord(a.reader))
+
+	if err != nil {
+		return 0, NewlineError("Could not read a rating!")
+	}
+
+	a.consumed = append(a.consumed, rating.String())
+
+	return rating, nil
+}
+
+// Text reads the rest of the current line like Title, but allows it to be
+// empty
+func (a *Args) Text() string {
+	text := strings.Join(strings.Fields(a.Line()), " ")
+	a.consumed = append(a.consumed, text)
+
+	return text
+}
+
+// Seed reads a random seed from the rest of the current line, or picks one from
+// the clock if the line is empty. Either way the seed is consumed, so the
+// arguments read back as the same seed.
+func (a *Args) Seed() (int64, Error) {
+	text := strings.TrimSpace(a.Line())
+	seed := now().UnixNano()
+
+	if text != "" {
+		var err error
+
+		if seed, err = strconv.ParseInt(text, 10, 64); err != nil {
+			return 0, RegularError("Could not read a seed!")
+		}
+	}
+
+	a.consumed = append(a.consumed, strconv.FormatInt(seed, 10))
+
+	return seed, nil
+}
+
+// User reads a user from the rest of the line, or takes the current user if
+// the line is empty. Either way the user is consumed, with unattributedName
+// for the unattributed user, so the arguments read back as the same user
+// whoever is current.
+func (a *Args) User(current string) (string, Error) {
+	user := strings.TrimSpace(a.Line())
+
+	if user == "" {
+		user = userName(current)
+	} else if user != unattributedName && !isUserName(user) {
+		return "", RegularError("Could not read a user!")
+	}
+
+	a.consumed = append(a.consumed, user)
+
+	if user == unattributedName {
+		return unattributedUser, nil
+	}
+
+	return user, nil
+}
+
+// Date reads a date as YYYY-MM-DD, "today", or "+N" for N days from today.
+// The date is consumed as YYYY-MM-DD, so the arguments read back as the same
+// date on another day.
+func (a *Args) Date() (time.Time, Error) {
+	word := ReadWord(a.reader)
+	date, err := parseDate(word)
+
+	if err != nil {
+		return time.Time{}, NewlineError("Could not read a date!")
+	}
+
+	a.consumed = append(a.consumed, date.Format(fmtDate))
+
+	return date, nil
+}
+
+// Time takes the current time as the time a Command that changes Collections
+// happens at. The time is consumed as @ followed by an RFC 3339 time before the
+// other arguments, where it is read back when the journal is replayed, so the
+// Command happens at the same time again. Only journaled arguments can give a
+// time, so one typed at the prompt is an ordinary argument.
+func (a *Args) Time() time.Time {
+	at := now().Truncate(time.Second)
+
+	if a.journaled {
+		if given, ok := a.journaledTime(); ok {
+			at = given
+		}
+	}
+
+	a.consumed = append(a.consumed, "@"+at.Format(time.RFC3339))
+
+	return at
+}
+
+// journaledTime reads the time Time consumed, if the arguments start with one
+func (a *Args) journaledTime() (time.Time, bool) {
+	for {
+		r, _, err := a.reader.ReadRune()
+
+		if err != nil {
+			break
+		} else if r != ' ' && r != '\t' {
+			_ = a.reader.UnreadRune()
+
+			break
+		}
+	}
+
+	// a journal entry is a single line that is already buffered
+	prefix, _ := a.reader.Peek(a.reader.Buffered())
+
+	if len(prefix) == 0 || prefix[0] != '@' {
+		return time.Time{}, false
+	}
+
+	word := prefix
+
+	if end := bytes.IndexFunc(prefix, unicode.IsSpace); end >= 0 {
+		word = prefix[:end]
+	}
+
+	given, err := time.Parse(time.RFC3339, string(word[1:]))
+
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	_, _ = a.reader.Discard(len(word))
+
+	return given, true
+}
+
+func parseDate(word string) (time.Time, error) {
+	if word == "today" {
+		return today(), nil
+	}
+
+	if strings.HasPrefix(word, "+") {
+		days, err := strconv.Atoi(word[1:])
+
+		if err != nil || days < 0 {
+			return time.Time{}, errors.New("invalid number of days")
+		}
+
+		return today().AddDate(0, 0, days), nil
+	}
+
+	return time.Parse(fmtDate, word)
+}
+
+// Answer reads a one-word answer to a question, or returns an empty string if
+// the input ends first
+func (a *Args) Answer() string {
+	SkipWhitespace(a.reader)
+
+	if _, err := a.reader.Peek(1); err != nil {
+		return ""
+	}
+
+	return ReadWord(a.reader)
+}
+
+// Consumed returns the arguments read since the last command name, in a form
+// that reads back as the same arguments
+func (a *Args) Consumed() string {
+	return strings.Join(a.consumed, " ")
+}
+
+// Command reads the next two-letter command name, skipping whitespace before
+// each letter. Returns false if the input ends first.
+func (a *Args) Command() (string, bool) {
+	const commandLength = 2
+
+	var command strings.Builder
+	a.consumed = a.consumed[:0]
+
+	for i := 0; i < commandLength; i++ {
+		SkipWhitespace(a.reader)
+		r, _, err := a.reader.ReadRune()
+
+		if err == io.EOF {
+			return "", false
+		} else if err != nil {
+			panic(err)
+		}
+
+		command.WriteRune(r)
+	}
+
+	return command.String(), true
+}
+
+// Command is a handler for one command. It reads its arguments from args and
+// writes its results to out.
+type Command func(session *Session, args *Args, out io.Writer) Error
+
+const cmdQuit = "qq"
+
+// Dispatcher maps command names to the Commands that implement them
+type Dispatcher struct {
+	commands map[string]Command
+	mutating map[string]bool
+}
+
+// NewDispatcher creates a Dispatcher that knows no commands
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{make(map[string]Command), make(map[string]bool)}
+}
+
+// Register adds a Command to this Dispatcher, replacing any Command that
+// already has that name
+func (d *Dispatcher) Register(name string, command Command) {
+	d.commands[name] = command
+	delete(d.mutating, name)
+}
+
+// RegisterMutating adds a Command that changes the Library or Catalog. Its
+// arguments alone must determine the change, so that replaying it on the same
+// state has the same effect.
+func (d *Dispatcher) RegisterMutating(name string, command Command) {
+	d.commands[name] = command
+	d.mutating[name] = true
+}
+
+// Execute runs a single Command by name. If it succeeds and changes the
+// Library or Catalog, it is appended to the Session's Journal, written to its
+// Storage, or marks the Session as having unsaved changes.
+func (d *Dispatcher) Execute(session *Session, name string, args *Args, out io.Writer) Error {
+	command, ok := d.commands[name]
+
+	if !ok {
+		return NewlineError("Unrecognized command!")
+	}
+
+	if err := command(session, args, out); err != nil {
+		return err
+	}
+
+	if !d.mutating[name] {
+		return nil
+	}
+
+	// pages of a listing would no longer line up with what changed
+	session.listing = nil
+
+	if session.journal != nil {
+		if err := session.journal.Append(name, args.Consumed()); err != nil {
+			return RegularError(errUnwritableJournal)
+		}
+	} else if session.storage != nil {
+		if err := session.store(); err != nil {
+			return RegularError(errUnwritableStorage)
+		}
+	} else {
+		session.dirty = true
+	}
+
+	if session.autosave != nil {
+		if err := session.autosave.Changed(session); err != nil {
+			return RegularError(errAutosave)
+		}
+	}
+
+	return nil
+}
+
+// Run prompts for and executes Commands read from in until a quit command or
+// the end of input, writing all output to out
+func (d *Dispatcher) Run(session *Session, in io.Reader, out io.Writer) {
+	args := NewArgs(in)
+
+	for {
+		fmt.Fprint(out, "\nEnter command: ")
+
+		name, ok := args.Command()
+
+		if !ok || name == cmdQuit {
+			if confirmQuit(session, args, out, ok) {
+				break
+			}
+
+			continue
+		}
+
+		session.mutex.Lock()
+		err := d.Execute(session, name, args, out)
+		session.mutex.Unlock()
+
+		if err != nil {
+			if err.ShouldSkipNewline() {
+				args.Line()
+			}
+
+			fmt.Fprintln(out, err)
+		}
+	}
+
+	session.mutex.Lock()
+	_ = clearAll(session, args, out)
+	session.mutex.Unlock()
+
+	fmt.Fprintln(out, "Done")
+}
+
+// confirmQuit deals with unsaved changes before quitting. An Autosave saves
+// them unless a person is there to ask; a person is asked whether to save
+// them, discard them, or not quit after all. canAsk is false if the input has
+// ended. Returns false if the Session should not quit.
+func confirmQuit(session *Session, args *Args, out io.Writer, canAsk bool) bool {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	if !session.dirty {
+		return true
+	}
+
+	if session.autosave != nil && (!session.interactive || !canAsk) {
+		if err := session.SaveFile(session.autosave.Filename()); err != nil {
+			fmt.Fprintln(out, errAutosave)
+		} else {
+			fmt.Fprintln(out, "Data saved")
+		}
+
+		return true
+	} else if !session.interactive {
+		return true
+	} else if !canAsk {
+		fmt.Fprintln(out, "Unsaved changes discarded")
+
+		return true
+	}
+
+	fmt.Fprint(out, "There are unsaved changes. Save them before quitting? (y/n/c): ")
+
+	switch args.Answer() {
+	case "y":
+		var filename string
+
+		if session.autosave != nil {
+			filename = session.autosave.Filename()
+		} else {
+			fmt.Fprint(out, "Save to file: ")
+			filename = args.Answer()
+		}
+
+		if filename == "" || session.SaveFile(filename) != nil {
+			fmt.Fprintln(out, errUnopenableFile)
+
+			return false
+		}
+
+		fmt.Fprintln(out, "Data saved")
+
+		return true
+	case "n":
+		session.markClean()
+
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,112 @@
+// MIT License
+//
+// Copyright (c) 2019 Gregory Meyer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"strings"
+)
+
+// TitleSimilarity scores how closely a title resembles a query from 0 (not at
+// all) to 1 (identical, ignoring case). The score is the better of comparing
+// the whole strings and comparing them word by word, so that both misspellings
+// ("Showbot") and missing or reordered words ("nothing much ado") score well.
+func TitleSimilarity(query, title string) float64 {
+	query, title = strings.ToLower(query), strings.ToLower(title)
+
+	whole := stringSimilarity(query, title)
+	words := wordSimilarity(strings.Fields(query), strings.Fields(title))
+
+	if words > whole {
+		return words
+	}
+
+	return whole
+}
+
+// stringSimilarity is one minus the edit distance between two strings divided
+// by the length of the longer one
+func stringSimilarity(a, b string) float64 {
+	runesA, runesB := []rune(a), []rune(b)
+	longest := maxInt(len(runesA), len(runesB))
+
+	if longest == 0 {
+		return 1
+	}
+
+	return 1 - float64(editDistance(runesA, runesB))/float64(longest)
+}
+
+// wordSimilarity averages, over every word of the query, the similarity of the
+// title word that resembles it best. Words of the title the query doesn't
+// mention count against it, but only by half as much.
+func wordSimilarity(query, title []string) float64 {
+	if len(query) == 0 || len(title) == 0 {
+		return 0
+	}
+
+	total := 0.0
+
+	for _, queryWord := range query {
+		best := 0.0
+
+		for _, titleWord := range title {
+			if similarity := stringSimilarity(queryWord, titleWord); similarity > best {
+				best = similarity
+			}
+		}
+
+		total += best
+	}
+
+	unmatched := float64(maxInt(0, len(title)-len(query)))
+
+	return total / (float64(len(query)) + unmatched/2)
+}
+
+// editDistance computes the Levenshtein distance between two strings of runes
+func editDistance(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			substitution := previous[j-1]
+
+			if a[i-1] != b[j-1] {
+				substitution++
+			}
+
+			current[j] = minInt(substitution, minInt(previous[j]+1, current[j-1]+1))
+		}
+
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
@@ -0,0 +1,79 @@
+// MIT License
+//
+// Copyright (c) 2019 Gregory Meyer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindFuzzy(t *testing.T) {
+	library := NewLibrary()
+
+	for _, title := range []string{
+		"Alien", "Aliens", "Brazil", "Much Ado About Nothing", "Showboat",
+	} {
+		if _, err := library.AddRecord("DVD", title); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		query string
+		limit int
+		want  []string
+	}{
+		{"Allien", 10, []string{"Alien", "Aliens"}},
+		{"aliens", 10, []string{"Aliens", "Alien"}},
+		{"Allien", 1, []string{"Alien"}},
+		{"Showbot", 10, []string{"Showboat"}},
+		{"nothing much ado", 10, []string{"Much Ado About Nothing"}},
+		{"Zardoz", 10, nil},
+	} {
+		var titles []string
+
+		for _, record := range library.FindFuzzy(test.query, test.limit) {
+			titles = append(titles, record.title)
+		}
+
+		if !reflect.DeepEqual(titles, test.want) {
+			t.Errorf("ff %s found %q, want %q", test.query, titles, test.want)
+		}
+	}
+}
+
+func TestTitleSimilarity(t *testing.T) {
+	for _, test := range []struct {
+		query, title string
+		want         float64
+	}{
+		{"alien", "ALIEN", 1},
+		{"Allien", "Alien", 1 - 1.0/6},
+		{"abc", "xyz", 0},
+		{"", "", 1},
+	} {
+		if got := TitleSimilarity(test.query, test.title); got != test.want {
+			t.Errorf("TitleSimilarity(%q, %q) = %v, want %v", test.query, test.title, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+// MIT License
+//
+// Copyright (c) 2019 Gregory Meyer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	journalSuffix        = ".journal"
+	journalHeaderFormat  = "mediamanager journal %08x\n"
+	errUnwritableJournal = "Could not write to the journal!"
+)
+
+// Journal is an append-only log of the mutating commands executed since the
+// last snapshot of a data file. It starts with the checksum of that snapshot,
+// so a journal that was already compacted into a newer snapshot is ignored.
+type Journal struct {
+	dataFilename string
+	file         *os.File
+
+	// numEntries is the number of commands journaled since the snapshot
+	numEntries int
+}
+
+// OpenJournal restores a Session from a data file, replays the data file's
+// journal on top of it, and opens the journal so that further changes are
+// appended to it. Returns the number of commands replayed.
+func OpenJournal(dataFilename string, session *Session, dispatcher *Dispatcher) (*Journal, int, error) {
+	snapshot, err := ioutil.ReadFile(dataFilename)
+
+	if err != nil && !os.IsNotExist(err) {
+		return nil, 0, err
+	}
+
+	if len(snapshot) > 0 {
+		if err := session.Restore(bufio.NewReader(bytes.NewReader(snapshot))); err != nil {
+			return nil, 0, fmt.Errorf("%s: %v", dataFilename, err)
+		}
+	}
+
+	journal := &Journal{dataFilename, nil, 0}
+	entries, err := journal.readEntries(crc32.ChecksumIEEE(snapshot))
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for i, entry := range entries {
+		args := newJournaledArgs(entry)
+		name, _ := args.Command()
+
+		if err := dispatcher.Execute(session, name, args, ioutil.Discard); err != nil {
+			return nil, 0, fmt.Errorf("%s: entry %d: %v", journal.filename(), i+1, err)
+		}
+	}
+
+	// rewrite the journal without any stale or partially written entries
+	if err := journal.reset(crc32.ChecksumIEEE(snapshot), entries); err != nil {
+		return nil, 0, err
+	}
+
+	session.journal = journal
+	session.markClean()
+
+	return journal, len(entries), nil
+}
+
+// Covers returns true if filename names the data file of this Journal
+func (j *Journal) Covers(filename string) bool {
+	a, errA := filepath.Abs(filename)
+	b, errB := filepath.Abs(j.dataFilename)
+
+	return errA == nil && errB == nil && a == b
+}
+
+// Append adds a command and its arguments to the end of this Journal, making
+// sure it reaches the disk before returning
+func (j *Journal) Append(name, args string) error {
+	entry := name
+
+	if args != "" {
+		entry += " " + args
+	}
+
+	if _, err := fmt.Fprintln(j.file, entry); err != nil {
+		return err
+	}
+
+	if err := j.file.Sync(); err != nil {
+		return err
+	}
+
+	j.numEntries++
+
+	return nil
+}
+
+// NumEntries returns the number of commands journaled since the data file was
+// last saved, which restoring it would discard
+func (j *Journal) NumEntries() int {
+	return j.numEntries
+}
+
+// Compact saves a Session as the new snapshot of the data file and empties
+// this Journal. The snapshot is replaced atomically, and the old journal no
+// longer matches it, so a crash at any point recovers the same state.
+func (j *Journal) Compact(session *Session) error {
+	var snapshot bytes.Buffer
+	session.Save(&snapshot)
+
+	if err := writeFileAtomically(j.dataFilename, snapshot.Bytes()); err != nil {
+		return err
+	}
+
+	return j.reset(crc32.ChecksumIEEE(snapshot.Bytes()), nil)
+}
+
+// Close closes the file underlying this Journal
+func (j *Journal) Close() error {
+	return j.file.Close()
+}
+
+func (j *Journal) filename() string {
+	return j.dataFilename + journalSuffix
+}
+
+// readEntries returns the complete entries of this Journal if it was written
+// on top of the snapshot with the given checksum
+func (j *Journal) readEntries(checksum uint32) ([]string, error) {
+	contents, err := ioutil.ReadFile(j.filename())
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	lines := strings.SplitAfter(string(contents), "\n")
+
+	if len(lines) == 0 || lines[0] != fmt.Sprintf(journalHeaderFormat, checksum) {
+		return nil, nil
+	}
+
+	var entries []string
+
+	for _, line := range lines[1:] {
+		// a crash can leave the last entry without its newline
+		if !strings.HasSuffix(line, "\n") {
+			break
+		}
+
+		entries = append(entries, strings.TrimSuffix(line, "\n"))
+	}
+
+	return entries, nil
+}
+
+// reset replaces this Journal with one for the snapshot with the given
+// checksum, containing only the given entries
+func (j *Journal) reset(checksum uint32, entries []string) error {
+	var contents strings.Builder
+	contents.WriteString(fmt.Sprintf(journalHeaderFormat, checksum))
+
+	for _, entry := range entries {
+		contents.WriteString(entry)
+		contents.WriteRune('\n')
+	}
+
+	if err := writeFileAtomically(j.filename(), []byte(contents.String())); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(j.filename(), os.O_WRONLY|os.O_APPEND, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	if j.file != nil {
+		_ = j.file.Close()
+	}
+
+	j.file = file
+	j.numEntries = len(entries)
+
+	return nil
+}
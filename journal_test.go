@@ -0,0 +1,275 @@
+// MIT License
+//
+// Copyright (c) 2019 Gregory Meyer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runCommand executes one line of input as a command, failing the test if the
+// command fails, and returns its output
+func runCommand(t *testing.T, session *Session, dispatcher *Dispatcher, line string) string {
+	t.Helper()
+
+	var out bytes.Buffer
+	args := NewArgs(strings.NewReader(line + "\n"))
+	name, _ := args.Command()
+
+	if err := dispatcher.Execute(session, name, args, &out); err != nil {
+		t.Fatalf("%s: %v", line, err)
+	}
+
+	return out.String()
+}
+
+// tempDir creates a directory for a test, which the test must remove
+func tempDir(t *testing.T) string {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "mediamanager")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+// openJournaled opens a Session journaled to a data file, failing the test if
+// the journal can't be recovered
+func openJournaled(t *testing.T, dataFilename string) (*Session, *Dispatcher, *Journal) {
+	t.Helper()
+
+	session := NewSession()
+	dispatcher := NewCommandDispatcher()
+	journal, _, err := OpenJournal(dataFilename, session, dispatcher)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return session, dispatcher, journal
+}
+
+func TestJournalRecoversIDsAfterDeletionAndSave(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	dataFilename := filepath.Join(dir, "data.txt")
+	session, dispatcher, journal := openJournaled(t, dataFilename)
+
+	for _, line := range []string{"ar DVD a", "ar DVD b", "dr b", "sA " + dataFilename, "ar DVD c", "ac x", "am x 3"} {
+		runCommand(t, session, dispatcher, line)
+	}
+
+	_ = journal.Close()
+
+	recovered, _, journal := openJournaled(t, dataFilename)
+	defer journal.Close()
+
+	record, err := recovered.library.FindRecordByID(3)
+
+	if err != nil || record.Title() != "c" {
+		t.Fatalf("record 3 = %v, %v; want c", record, err)
+	}
+
+	collection, err := recovered.catalog.FindCollection("x")
+
+	if err != nil || !collection.ContainsDeep(record) {
+		t.Errorf("collection x = %v, %v; want it to contain record 3", collection, err)
+	}
+
+	if id := runCommand(t, recovered, dispatcher, "ar DVD d"); id != "Record 4 added\n" {
+		t.Errorf("ar after recovery printed %q, want record 4", id)
+	}
+}
+
+func TestJournalReplaysOnTopOfSnapshot(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	dataFilename := filepath.Join(dir, "data.txt")
+	session, dispatcher, journal := openJournaled(t, dataFilename)
+
+	runCommand(t, session, dispatcher, "ar DVD Alien")
+	runCommand(t, session, dispatcher, "sA "+dataFilename)
+	runCommand(t, session, dispatcher, "mr 1 4")
+	_ = journal.Close()
+
+	recovered, _, journal := openJournaled(t, dataFilename)
+	defer journal.Close()
+
+	if record, err := recovered.library.FindRecordByID(1); err != nil || record.Rating() != 4*ratingUnit {
+		t.Errorf("record 1 = %v, %v; want a rating of 4", record, err)
+	}
+}
+
+func TestJournalRefusesToRestoreAnotherFile(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	dataFilename := filepath.Join(dir, "data.txt")
+	otherFilename := filepath.Join(dir, "other.txt")
+	session, dispatcher, journal := openJournaled(t, dataFilename)
+	defer journal.Close()
+
+	runCommand(t, session, dispatcher, "sA "+otherFilename)
+	runCommand(t, session, dispatcher, "ar DVD Alien")
+	runCommand(t, session, dispatcher, "sA "+dataFilename)
+	saved, _ := ioutil.ReadFile(dataFilename)
+
+	args := NewArgs(strings.NewReader(otherFilename + "\n"))
+
+	if err := dispatcher.Execute(session, "rA", args, ioutil.Discard); err == nil || err.Error() != errRestoreOverJournal {
+		t.Errorf("rA of another file = %v, want %q", err, errRestoreOverJournal)
+	}
+
+	if after, _ := ioutil.ReadFile(dataFilename); !bytes.Equal(saved, after) {
+		t.Errorf("rA of another file changed the journaled data file to %q", after)
+	}
+
+	runCommand(t, session, dispatcher, "rA "+dataFilename)
+}
+
+func TestJournalReplaysCollectionTimes(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	defer func(clock func() time.Time) { now = clock }(now)
+	created := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	modified := created.Add(time.Hour)
+
+	dataFilename := filepath.Join(dir, "data.txt")
+	session, dispatcher, journal := openJournaled(t, dataFilename)
+
+	now = func() time.Time { return created }
+	runCommand(t, session, dispatcher, "ar DVD Alien")
+	runCommand(t, session, dispatcher, "ac x")
+	now = func() time.Time { return modified }
+	runCommand(t, session, dispatcher, "am x 1")
+	runCommand(t, session, dispatcher, "ac @2020-01-02T03:04:05Z")
+	runCommand(t, session, dispatcher, "ac @home")
+	_ = journal.Close()
+
+	now = func() time.Time { return modified.AddDate(1, 0, 0) }
+	recovered, _, journal := openJournaled(t, dataFilename)
+	defer journal.Close()
+
+	for _, test := range []struct {
+		name              string
+		created, modified time.Time
+	}{
+		{"x", created, modified},
+		// a time typed at the prompt is a name, not the time of the command
+		{"@2020-01-02T03:04:05Z", modified, modified},
+		{"@home", modified, modified},
+	} {
+		collection, err := recovered.catalog.FindCollection(test.name)
+
+		if err != nil {
+			t.Errorf("collection %s: %v", test.name, err)
+		} else if !collection.created.Equal(test.created) || !collection.modified.Equal(test.modified) {
+			t.Errorf("collection %s was created %v and modified %v, want %v and %v",
+				test.name, collection.created, collection.modified, test.created, test.modified)
+		}
+	}
+}
+
+func TestJournalRefusesToDiscardJournaledChanges(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	dataFilename := filepath.Join(dir, "data.txt")
+	session, dispatcher, journal := openJournaled(t, dataFilename)
+
+	runCommand(t, session, dispatcher, "ar DVD Alien")
+	runCommand(t, session, dispatcher, "sA "+dataFilename)
+	runCommand(t, session, dispatcher, "ar DVD Brazil")
+
+	args := NewArgs(strings.NewReader(dataFilename + "\n"))
+
+	if err := dispatcher.Execute(session, "rA", args, ioutil.Discard); err == nil || err.Error() != errRestoreDiscardsJournal {
+		t.Errorf("rA over journaled changes = %v, want %q", err, errRestoreDiscardsJournal)
+	}
+
+	session.interactive = true
+	args = NewArgs(strings.NewReader(dataFilename + "\nn\n"))
+
+	if err := dispatcher.Execute(session, "rA", args, ioutil.Discard); err == nil || session.library.NumRecords() != 2 {
+		t.Errorf("rA answered n = %v, want it cancelled", err)
+	}
+
+	_ = journal.Close()
+
+	recovered, _, journal := openJournaled(t, dataFilename)
+	defer journal.Close()
+
+	if recovered.library.NumRecords() != 2 {
+		t.Errorf("%d records recovered, want the journaled record kept", recovered.library.NumRecords())
+	}
+}
+
+func TestJournalRestoresAfterConfirmation(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	dataFilename := filepath.Join(dir, "data.txt")
+	session, dispatcher, journal := openJournaled(t, dataFilename)
+	defer journal.Close()
+
+	runCommand(t, session, dispatcher, "ar DVD Alien")
+	runCommand(t, session, dispatcher, "sA "+dataFilename)
+	runCommand(t, session, dispatcher, "ar DVD Brazil")
+	session.interactive = true
+	runCommand(t, session, dispatcher, "rA "+dataFilename+"\ny")
+
+	if session.library.NumRecords() != 1 || journal.NumEntries() != 0 {
+		t.Errorf("rA answered y left %d records and %d journaled changes, want 1 and 0",
+			session.library.NumRecords(), journal.NumEntries())
+	}
+}
+
+func TestOnlyJournaledArgsGiveTimes(t *testing.T) {
+	defer func(clock func() time.Time) { now = clock }(now)
+	current := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+	given := time.Date(2001, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	args := newJournaledArgs("@2001-01-02T03:04:05Z films\n")
+
+	if at, word := args.Time(), args.Word(); !at.Equal(given) || word != "films" {
+		t.Errorf("journaled arguments gave %v and %q, want %v and films", at, word, given)
+	}
+
+	args = NewArgs(strings.NewReader("@2001-01-02T03:04:05Z films\n"))
+
+	if at, word := args.Time(), args.Word(); !at.Equal(current) || word != "@2001-01-02T03:04:05Z" {
+		t.Errorf("typed arguments gave %v and %q, want %v and the typed time as a word", at, word, current)
+	}
+}
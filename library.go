@@ -26,29 +26,96 @@ import (
 	"bufio"
 	"fmt"
 	"io"
-	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 )
 
-type libraryByTitle map[string]*Record
+type libraryByTitle map[string][]*Record
+type libraryByFoldedTitle map[string][]*Record
 type libraryByID map[int]*Record
 
-// Library is a set of Records that can be indexed by title or by ID
+// Library is a set of Records that can be indexed by title or by ID, searched
+// by the trigrams of their titles, and listed in order of title or rating.
+// Every rating in a Library is on its RatingScale. Records can only share a
+// title if the Library allows duplicate titles.
 type Library struct {
-	byTitle libraryByTitle
-	byID    libraryByID
-	nextID  int
+	byTitle       libraryByTitle
+	byFoldedTitle libraryByFoldedTitle
+	byID          libraryByID
+	byTrigram     *TrigramIndex
+	inTitleOrder  *RecordOrder
+	inRatingOrder *RecordOrder
+	nextID        int
+	scale         RatingScale
+
+	// duplicateTitles is true if a Record can be added with, or given, the
+	// title of another Record
+	duplicateTitles bool
+
+	// foldedTitles is true if a Record can't be added with, or given, a title
+	// that differs from the title of another Record only in case, unless
+	// duplicate titles are allowed
+	foldedTitles bool
+
+	// articles are the articles that titles are sorted without, in lower case
+	articles []string
 }
 
-// NewLibrary creates an empty Library ready to track Records
+// NewLibrary creates an empty Library ready to track Records, rated on the
+// default RatingScale
 func NewLibrary() *Library {
-	return &Library{make(libraryByTitle), make(libraryByID), 1}
+	return &Library{
+		make(libraryByTitle),
+		make(libraryByFoldedTitle),
+		make(libraryByID),
+		NewTrigramIndex(),
+		NewRecordOrder(TitleLess),
+		NewRecordOrder(RatingLess),
+		1,
+		defaultRatingScale,
+		false,
+		false,
+		nil,
+	}
 }
 
-// RestoreLibrary deserializes a Library from a *bufio.Reader
-func RestoreLibrary(reader *bufio.Reader) (*Library, Error) {
+// RestoreLibrary deserializes a Library from a *bufio.Reader. A Library saved
+// before ratings had scales is moved to the default RatingScale, and one saved
+// before it kept its next ID gives out IDs after its highest. Titles are
+// sorted without articles, in lower case.
+func RestoreLibrary(reader *bufio.Reader, articles []string) (*Library, Error) {
 	library := NewLibrary()
+	library.articles = articles
+	legacy := true
+
+	SkipWhitespace(reader)
+
+	if next, err := reader.Peek(1); err == nil && next[0] == 's' {
+		scale, err := ParseRatingScale(strings.TrimPrefix(ReadLine(reader), "scale "))
+
+		if err != nil {
+			return nil, NewlineError(ErrInvalidFile)
+		}
+
+		library.scale = scale
+		legacy = false
+	} else {
+		library.scale = legacyRatingScale
+	}
+
+	nextID := 1
+	SkipWhitespace(reader)
+
+	if next, err := reader.Peek(1); err == nil && next[0] == 'n' {
+		fields := strings.Fields(ReadLine(reader))
+
+		if len(fields) != 2 || fields[0] != "next" {
+			return nil, NewlineError(ErrInvalidFile)
+		} else if nextID, err = strconv.Atoi(fields[1]); err != nil || nextID < 1 {
+			return nil, NewlineError(ErrInvalidFile)
+		}
+	}
 
 	numRecords, err := ReadInt(reader)
 
@@ -56,10 +123,13 @@ func RestoreLibrary(reader *bufio.Reader) (*Library, Error) {
 		return nil, NewlineError(ErrInvalidFile)
 	}
 
+	// Records with the same title are read whether or not the Library allows
+	// them, so a file saved while it did can still be read
 	maxID := 0
+	records := make([]*Record, 0, numRecords)
 
 	for i := 0; i < numRecords; i++ {
-		record, err := RestoreRecord(reader)
+		record, err := RestoreRecord(reader, library.scale, legacy)
 
 		if err != nil {
 			return nil, err
@@ -68,34 +138,82 @@ func RestoreLibrary(reader *bufio.Reader) (*Library, Error) {
 		// no duplicate records allowed
 		if _, ok := library.byID[record.id]; ok {
 			return nil, NewlineError(ErrInvalidFile)
-		} else if _, ok := library.byTitle[record.title]; ok {
-			return nil, NewlineError(ErrInvalidFile)
 		}
 
-		library.byTitle[record.title] = record
-		library.byID[record.id] = record
+		record.sortKey = collationKey(record.title, articles)
+		library.indexKeys(record)
+		records = append(records, record)
 
 		if record.id > maxID {
 			maxID = record.id
 		}
 	}
 
-	library.nextID = maxID + 1
+	// sorting once is much faster than inserting each Record in order
+	library.inTitleOrder = newSortedRecordOrder(records, TitleLess)
+	library.inRatingOrder = newSortedRecordOrder(records, RatingLess)
+
+	// IDs of deleted Records are never given out again, so that commands
+	// journaled after a save name the same Records when they are replayed
+	library.nextID = maxInt(nextID, maxID+1)
+
+	if legacy {
+		library.Rescale(defaultRatingScale, nil)
+	}
 
 	return library, nil
 }
 
-const errNoSuchRecordTitle = "No record with that title!"
+const (
+	errNoSuchRecordTitle    = "No record with that title!"
+	errAmbiguousRecordTitle = "Several records have that title! Add [medium] or [#ID] to choose one."
+)
 
-// FindRecordByTitle indexes into a Library's set of Records by title
-func (l *Library) FindRecordByTitle(title string) (*Record, Error) {
-	record, ok := l.byTitle[title]
+// FindRecordsByTitle returns the Records with a title, sorted by ID in
+// ascending order. A title that no Record has may be followed by a qualifier
+// in brackets that picks out the Records sharing a title with a medium, as in
+// "Alien [VHS]", or the Record with an ID, as in "Alien [#12]".
+func (l *Library) FindRecordsByTitle(title string) ([]*Record, Error) {
+	if records, ok := l.byTitle[title]; ok {
+		return append([]*Record(nil), records...), nil
+	}
 
-	if !ok {
+	title, qualifier := splitQualifier(title)
+	var records []*Record
+
+	for _, record := range l.byTitle[title] {
+		if qualifier == record.medium || qualifier == fmt.Sprintf("#%d", record.id) {
+			records = append(records, record)
+		}
+	}
+
+	if len(records) == 0 {
 		return nil, RegularError(errNoSuchRecordTitle)
 	}
 
-	return record, nil
+	return records, nil
+}
+
+// splitQualifier splits a title that ends with a qualifier in brackets, as
+// FindRecordsByTitle reads it, into the title and the qualifier
+func splitQualifier(title string) (string, string) {
+	open := strings.LastIndex(title, " [")
+
+	if open < 0 || !strings.HasSuffix(title, "]") {
+		return title, ""
+	}
+
+	return title[:open], title[open+2 : len(title)-1]
+}
+
+// QualifiedTitle returns the title of a Record, qualified by its ID if other
+// Records share it, so that FindRecordsByTitle finds it and only it
+func (l *Library) QualifiedTitle(record *Record) string {
+	if len(l.byTitle[record.title]) > 1 {
+		return fmt.Sprintf("%s [#%d]", record.title, record.id)
+	}
+
+	return record.title
 }
 
 // FindRecordByID indexes into a Library's set of Records by ID
@@ -109,40 +227,36 @@ func (l *Library) FindRecordByID(id int) (*Record, Error) {
 	return record, nil
 }
 
-const errDuplicateRecordTitle = "Library already has a record with this title!"
+const (
+	errDuplicateRecordTitle       = "Library already has a record with this title!"
+	errDuplicateFoldedRecordTitle = "Library already has a record with this title in another case!"
+)
 
 // AddRecord adds a Record into the Library
 func (l *Library) AddRecord(medium, title string) (int, Error) {
-	if _, ok := l.byTitle[title]; ok {
-		return 0, RegularError(errDuplicateRecordTitle)
+	if err := l.checkTitle(title, nil); err != nil {
+		return 0, err
 	}
 
 	id := l.nextID
 	record := NewRecord(medium, title, id)
+	record.sortKey = collationKey(title, l.articles)
 	l.nextID++
 
-	l.byTitle[title] = record
-	l.byID[id] = record
+	l.index(record)
 
 	return id, nil
 }
 
 // DeleteRecord erases a Record from this Library's set
-func (l *Library) DeleteRecord(title string) (*Record, Error) {
-	record, ok := l.byTitle[title]
-
-	if !ok {
-		return nil, RegularError(errNoSuchRecordTitle)
-	}
-
+func (l *Library) DeleteRecord(record *Record) Error {
 	if record.numCollections > 0 {
-		return nil, RegularError("Cannot delete a record that is a member of a collection!")
+		return RegularError("Cannot delete a record that is a member of a collection!")
 	}
 
-	delete(l.byTitle, record.title)
-	delete(l.byID, record.id)
+	l.unindex(record)
 
-	return record, nil
+	return nil
 }
 
 // Clear erases all Records from this Library's set
@@ -153,7 +267,7 @@ func (l *Library) Clear(catalog *Catalog) Error {
 		}
 	}
 
-	*l = *NewLibrary()
+	l.reset()
 
 	return nil
 }
@@ -161,101 +275,337 @@ func (l *Library) Clear(catalog *Catalog) Error {
 // ClearAll erases all Records from a Library and all Collections from a Catalog
 func (l *Library) ClearAll(catalog *Catalog) {
 	catalog.Clear()
+	l.reset()
+}
+
+// reset erases all Records from this Library, keeping its RatingScale, which
+// titles it allows and how they are sorted
+func (l *Library) reset() {
+	scale, duplicateTitles, foldedTitles, articles := l.scale, l.duplicateTitles, l.foldedTitles, l.articles
 	*l = *NewLibrary()
+	l.scale, l.duplicateTitles, l.foldedTitles, l.articles = scale, duplicateTitles, foldedTitles, articles
+}
+
+// checkTitle returns an error if a Record, or a new Record if it is nil, can't
+// be given a title because another Record has it
+func (l *Library) checkTitle(title string, record *Record) Error {
+	if l.duplicateTitles {
+		return nil
+	}
+
+	if _, ok := l.byTitle[title]; ok {
+		return RegularError(errDuplicateRecordTitle)
+	}
+
+	if !l.foldedTitles {
+		return nil
+	}
+
+	for _, other := range l.byFoldedTitle[foldTitle(title)] {
+		if other != record {
+			return RegularError(errDuplicateFoldedRecordTitle)
+		}
+	}
+
+	return nil
+}
+
+// NearDuplicates returns the groups of Records whose titles are the same when
+// compared case insensitively, each sorted by title and then by ID, and sorted
+// by the title of their first Record
+func (l *Library) NearDuplicates() [][]*Record {
+	var groups [][]*Record
+
+	for _, records := range l.byFoldedTitle {
+		if len(records) > 1 {
+			group := append([]*Record(nil), records...)
+			sort.Slice(group, func(i, j int) bool {
+				return TitleLess(group[i], group[j])
+			})
+			groups = append(groups, group)
+		}
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return TitleLess(groups[i][0], groups[j][0])
+	})
+
+	return groups
 }
 
 // Save serializes a Library to an io.Writer in a format suitable for recovery
 func (l *Library) Save(writer io.Writer) {
-	FprintfOrPanic(writer, "%d\n", len(l.byTitle))
+	FprintfOrPanic(writer, "scale %s\n", l.scale)
+	FprintfOrPanic(writer, "next %d\n", l.nextID)
+	FprintfOrPanic(writer, "%d\n", len(l.byID))
 
 	for _, record := range l.sortedRecords() {
 		record.Save(writer)
 	}
 }
 
-// FindString returns all a string of all Records whose title contains a given
-// substring, case insensitively. The Records are sorted by title in ascending
-// order.
-func (l *Library) FindString(substr string) (string, Error) {
-	re := regexp.MustCompile(fmt.Sprintf("(?i)%s", regexp.QuoteMeta(substr)))
+// Search returns all Records whose titles match a TitleMatcher, sorted by
+// title in ascending order. Only Records that the trigram index can't rule
+// out are checked.
+func (l *Library) Search(matcher *TitleMatcher) []SearchResult {
+	candidates, ok := matcher.candidates(l.byTrigram)
+
+	if !ok {
+		return l.searchLinear(matcher)
+	}
+
+	return searchRecords(matcher, candidates)
+}
 
+// searchLinear is Search without the trigram index
+func (l *Library) searchLinear(matcher *TitleMatcher) []SearchResult {
+	return searchRecords(matcher, l.byID)
+}
+
+func searchRecords(matcher *TitleMatcher, records map[int]*Record) []SearchResult {
+	var results []SearchResult
+
+	for _, record := range records {
+		if spans, ok := matcher.Match(record.title); ok {
+			results = append(results, SearchResult{record, spans})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return TitleLess(results[i].record, results[j].record)
+	})
+
+	return results
+}
+
+// minTitleSimilarity is the TitleSimilarity below which a Record isn't worth
+// suggesting
+const minTitleSimilarity = 0.5
+
+// FindFuzzy returns up to limit Records whose titles resemble a query,
+// most similar first. Records that are equally similar are sorted by title in
+// ascending order.
+func (l *Library) FindFuzzy(query string, limit int) []*Record {
+	similarities := make(map[*Record]float64)
 	var matches []*Record
 
-	for _, record := range l.byTitle {
-		if re.MatchString(record.title) {
+	for _, record := range l.sortedRecords() {
+		similarity := TitleSimilarity(query, record.title)
+
+		if similarity >= minTitleSimilarity {
+			similarities[record] = similarity
 			matches = append(matches, record)
 		}
 	}
 
-	if len(matches) == 0 {
-		return "", NewlineError("No records contain that string!")
-	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return similarities[matches[i]] > similarities[matches[j]]
+	})
 
-	SortRecordsByTitle(matches)
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
 
-	return SprintRecords(matches), nil
+	return matches
 }
 
 const msgLibraryEmpty = "Library is empty"
 
-// ListRatings returns a string of all Records sorted by rating in descending
-// order. Records with the same rating are sorted by title in ascending order.
-func (l *Library) ListRatings() string {
-	if len(l.byTitle) == 0 {
-		return msgLibraryEmpty
-	}
-
-	records := l.sortedRecords()
+// Uncollected returns the Records that are in no Collection, sorted by title in
+// ascending order
+func (l *Library) Uncollected() []*Record {
+	var uncollected []*Record
 
-	sort.SliceStable(records, func(i, j int) bool {
-		return records[i].rating > records[j].rating
-	})
+	for _, record := range l.sortedRecords() {
+		if record.numCollections == 0 {
+			uncollected = append(uncollected, record)
+		}
+	}
 
-	return SprintRecords(records)
+	return uncollected
 }
 
 // NumRecords returns the number of Records in the Library
 func (l *Library) NumRecords() int {
-	return len(l.byTitle)
+	return len(l.byID)
 }
 
-// ModifyTitle changes the title of a Record in the Library
-func (l *Library) ModifyTitle(record *Record, newTitle string) Error {
-	if _, ok := l.byTitle[newTitle]; ok {
-		return RegularError(errDuplicateRecordTitle)
+// ModifyTitle changes the title of a Record in the Library, moving it to its
+// new place in the Library and in every Collection of a Catalog
+func (l *Library) ModifyTitle(record *Record, newTitle string, catalog *Catalog) Error {
+	if err := l.checkTitle(newTitle, record); err != nil {
+		return err
 	}
 
-	delete(l.byTitle, record.title)
-	record.title = newTitle
-	l.byTitle[newTitle] = record
+	containing := catalog.withMember(record)
+
+	for _, collection := range containing {
+		collection.inTitleOrder.Remove(record)
+		collection.inRatingOrder.Remove(record)
+	}
+
+	l.unindex(record)
+	record.title, record.sortKey = newTitle, collationKey(newTitle, l.articles)
+	l.index(record)
+
+	for _, collection := range containing {
+		collection.inTitleOrder.Insert(record)
+		collection.inRatingOrder.Insert(record)
+	}
 
 	return nil
 }
 
+// SetRating changes the rating a user gives a Record in the Library, moving
+// the Record to its new place in order of rating in the Library and in every
+// Collection of a Catalog
+func (l *Library) SetRating(record *Record, user string, newRating Rating, catalog *Catalog) Error {
+	containing := catalog.withMember(record)
+
+	for _, collection := range containing {
+		collection.inRatingOrder.Remove(record)
+	}
+
+	l.inRatingOrder.Remove(record)
+	err := record.SetRating(user, newRating, l.scale)
+	l.inRatingOrder.Insert(record)
+
+	for _, collection := range containing {
+		collection.inRatingOrder.Insert(record)
+	}
+
+	return err
+}
+
+// Scale returns the RatingScale of every rating in the Library
+func (l *Library) Scale() RatingScale {
+	return l.scale
+}
+
+// Rescale moves every rating in the Library, including the rating of each user
+// and the ratings of Views, to another RatingScale, as if by RatingScale.Convert.
+// Ratings that become equal are ordered by title instead, in the Library and in
+// every Collection of a Catalog, which is nil if no Collection holds the
+// Records yet.
+func (l *Library) Rescale(scale RatingScale, catalog *Catalog) {
+	from := l.scale
+	l.scale = scale
+
+	if scale == from {
+		return
+	}
+
+	for _, record := range l.byID {
+		for user, rating := range record.ratings {
+			record.ratings[user] = scale.Convert(rating, from)
+		}
+
+		record.rating = record.Summary().Mean
+
+		for i := range record.views {
+			record.views[i].rating = scale.Convert(record.views[i].rating, from)
+		}
+	}
+
+	l.inRatingOrder = newSortedRecordOrder(l.inTitleOrder.Records(), RatingLess)
+
+	if catalog == nil {
+		return
+	}
+
+	for _, collection := range catalog.collections {
+		collection.inRatingOrder = newSortedRecordOrder(collection.sortedMembers(), RatingLess)
+	}
+}
+
+// SetArticles changes the articles, in lower case, that the titles of Records
+// in the Library are sorted without. It must be called before any Collection
+// holds the Records, since their orders are not changed.
+func (l *Library) SetArticles(articles []string) {
+	l.articles = articles
+	records := l.inTitleOrder.Records()
+
+	for _, record := range records {
+		record.sortKey = collationKey(record.title, articles)
+	}
+
+	l.inTitleOrder = newSortedRecordOrder(records, TitleLess)
+	l.inRatingOrder = newSortedRecordOrder(records, RatingLess)
+}
+
 func (l *Library) String() string {
-	if len(l.byTitle) == 0 {
+	if len(l.byID) == 0 {
 		return msgLibraryEmpty
 	}
 
-	var builder strings.Builder
-	builder.WriteString(fmt.Sprintf("Library contains %d records:\n", len(l.byTitle)))
-	builder.WriteString(SprintRecords(l.sortedRecords()))
-
-	return builder.String()
+	return NewLibraryListing(l).Render(0, nil)
 }
 
+// sortedRecords returns every Record in order of title. The slice must not be
+// modified.
 func (l *Library) sortedRecords() []*Record {
-	if len(l.byTitle) == 0 {
-		return []*Record{}
+	return l.inTitleOrder.Records()
+}
+
+// index adds a Record to every index of the Library
+func (l *Library) index(record *Record) {
+	l.indexKeys(record)
+	l.inTitleOrder.Insert(record)
+	l.inRatingOrder.Insert(record)
+}
+
+// indexKeys adds a Record to every index of the Library but its orders
+func (l *Library) indexKeys(record *Record) {
+	l.byTitle[record.title] = insertRecordByID(l.byTitle[record.title], record)
+	folded := foldTitle(record.title)
+	l.byFoldedTitle[folded] = insertRecordByID(l.byFoldedTitle[folded], record)
+	l.byID[record.id] = record
+	l.byTrigram.Add(record)
+}
+
+// unindex removes a Record from every index of the Library. It must be called
+// before the Record's title or rating changes.
+func (l *Library) unindex(record *Record) {
+	if sharing := removeRecord(l.byTitle[record.title], record); len(sharing) > 0 {
+		l.byTitle[record.title] = sharing
+	} else {
+		delete(l.byTitle, record.title)
 	}
 
-	sortedByTitle := make([]*Record, 0, len(l.byTitle))
+	folded := foldTitle(record.title)
 
-	for _, record := range l.byTitle {
-		sortedByTitle = append(sortedByTitle, record)
+	if sharing := removeRecord(l.byFoldedTitle[folded], record); len(sharing) > 0 {
+		l.byFoldedTitle[folded] = sharing
+	} else {
+		delete(l.byFoldedTitle, folded)
 	}
+	delete(l.byID, record.id)
+	l.byTrigram.Remove(record)
+	l.inTitleOrder.Remove(record)
+	l.inRatingOrder.Remove(record)
+}
+
+// insertRecordByID adds a Record to a slice of Records sorted by ID, keeping
+// it sorted
+func insertRecordByID(records []*Record, record *Record) []*Record {
+	i := sort.Search(len(records), func(i int) bool {
+		return records[i].id >= record.id
+	})
+
+	records = append(records, nil)
+	copy(records[i+1:], records[i:])
+	records[i] = record
 
-	SortRecordsByTitle(sortedByTitle)
+	return records
+}
+
+// removeRecord takes a Record out of a slice of Records, if it is there
+func removeRecord(records []*Record, record *Record) []*Record {
+	for i, other := range records {
+		if other == record {
+			return append(records[:i], records[i+1:]...)
+		}
+	}
 
-	return sortedByTitle
+	return records
 }
@@ -0,0 +1,198 @@
+// MIT License
+//
+// Copyright (c) 2019 Gregory Meyer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// fmtDate is how dates are read, printed and saved
+const fmtDate = "2006-01-02"
+
+// Loan is the lending of a Record to a borrower. A Loan that hasn't been
+// returned has a zero returned date.
+type Loan struct {
+	borrower string
+	lent     time.Time
+	due      time.Time
+	returned time.Time
+}
+
+// String prints a Loan as a line of a Record's loan history
+func (l Loan) String() string {
+	text := fmt.Sprintf("Lent to %s on %s, due %s", l.borrower, l.lent.Format(fmtDate), l.due.Format(fmtDate))
+
+	if l.returned.IsZero() {
+		return text + ", not returned"
+	}
+
+	return text + ", returned " + l.returned.Format(fmtDate)
+}
+
+// Lend lends this Record to a borrower from one date until a due date
+func (r *Record) Lend(borrower string, lent, due time.Time) Error {
+	if _, ok := r.CurrentLoan(); ok {
+		return RegularError("Record is already on loan!")
+	}
+
+	if due.Before(lent) {
+		return RegularError("Due date is before the date lent!")
+	}
+
+	r.loans = append(r.loans, Loan{borrower, lent, due, time.Time{}})
+
+	return nil
+}
+
+// Return ends the current Loan of this Record on a date
+func (r *Record) Return(returned time.Time) Error {
+	loan, ok := r.CurrentLoan()
+
+	if !ok {
+		return NewlineError("Record is not on loan!")
+	}
+
+	if returned.Before(loan.lent) {
+		return NewlineError("Return date is before the date lent!")
+	}
+
+	r.loans[len(r.loans)-1].returned = returned
+
+	return nil
+}
+
+// CurrentLoan returns the Loan of this Record that hasn't been returned yet.
+// Returns false if the Record isn't on loan.
+func (r *Record) CurrentLoan() (Loan, bool) {
+	if len(r.loans) == 0 || !r.loans[len(r.loans)-1].returned.IsZero() {
+		return Loan{}, false
+	}
+
+	return r.loans[len(r.loans)-1], true
+}
+
+// Loans returns every Loan of this Record, oldest first. The slice must not be
+// modified.
+func (r *Record) Loans() []Loan {
+	return r.loans
+}
+
+// loanStatus describes the current Loan of this Record, or returns an empty
+// string if it isn't on loan
+func (r *Record) loanStatus() string {
+	loan, ok := r.CurrentLoan()
+
+	if !ok {
+		return ""
+	}
+
+	return fmt.Sprintf("(lent to %s, due %s)", loan.borrower, loan.due.Format(fmtDate))
+}
+
+// saveLoans writes a line for each Loan of this Record, to follow the line of
+// the Record itself
+func (r *Record) saveLoans(writer io.Writer) {
+	for _, loan := range r.loans {
+		returned := "-"
+
+		if !loan.returned.IsZero() {
+			returned = loan.returned.Format(fmtDate)
+		}
+
+		FprintfOrPanic(writer, "loan %s %s %s %s\n",
+			loan.lent.Format(fmtDate), loan.due.Format(fmtDate), returned, loan.borrower)
+	}
+}
+
+// restoreLoan reads a Loan from the rest of a line written by saveLoans.
+// Returns false if the line is malformed.
+func (r *Record) restoreLoan(line string) bool {
+	fields := strings.SplitN(strings.TrimSpace(line), " ", 4)
+
+	if len(fields) < 4 || strings.TrimSpace(fields[3]) == "" {
+		return false
+	}
+
+	lent, err := time.Parse(fmtDate, fields[0])
+
+	if err != nil {
+		return false
+	}
+
+	due, err := time.Parse(fmtDate, fields[1])
+
+	if err != nil || due.Before(lent) {
+		return false
+	}
+
+	var returned time.Time
+
+	if fields[2] != "-" {
+		if returned, err = time.Parse(fmtDate, fields[2]); err != nil || returned.Before(lent) {
+			return false
+		}
+	}
+
+	// only the last Loan can be outstanding
+	if _, ok := r.CurrentLoan(); ok {
+		return false
+	}
+
+	r.loans = append(r.loans, Loan{strings.TrimSpace(fields[3]), lent, due, returned})
+
+	return true
+}
+
+// OnLoan returns the Records in a Library that are on loan, sorted by due date,
+// then by title, in ascending order. If overdueOn is not zero, only the
+// Records that were due before that date are returned.
+func (l *Library) OnLoan(overdueOn time.Time) []*Record {
+	var onLoan []*Record
+
+	for _, record := range l.sortedRecords() {
+		if loan, ok := record.CurrentLoan(); ok && (overdueOn.IsZero() || loan.due.Before(overdueOn)) {
+			onLoan = append(onLoan, record)
+		}
+	}
+
+	sort.SliceStable(onLoan, func(i, j int) bool {
+		first, _ := onLoan[i].CurrentLoan()
+		second, _ := onLoan[j].CurrentLoan()
+
+		return first.due.Before(second.due)
+	})
+
+	return onLoan
+}
+
+// today returns the current date, at midnight UTC so that it compares equal to
+// dates that are read
+func today() time.Time {
+	year, month, day := now().Date()
+
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
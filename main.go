@@ -24,21 +24,193 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
+	"strconv"
 	"strings"
+	"time"
 )
 
-var stdin *bufio.Reader
-
 func main() {
-	commands := map[string]func(*Library, *Catalog) Error{
+	journalFilename := flag.String("journal", "",
+		"journal every change made to this data file and recover from the journal on startup")
+	storeFilename := flag.String("store", "",
+		"load from and write every change to this file")
+	storeFormat := flag.String("store-format", "db",
+		"format of the -store file: \"db\" to write changes incrementally, or \"text\"")
+	autosaveFilename := flag.String("autosave-file", "mediamanager.txt",
+		"data file to autosave to and to save unsaved changes to on exit")
+	autosaveChanges := flag.Int("autosave-changes", 0,
+		"autosave after this many changes, or never if 0")
+	autosaveInterval := flag.Duration("autosave-interval", 0,
+		"autosave this often while there are unsaved changes, or never if 0")
+	suggest := flag.Bool("suggest", false,
+		"suggest similar titles when no record has the title given to fr or dr")
+	pager := flag.String("pager", defaultPager(),
+		"command that shows listings too tall for the terminal, or nothing to never use one")
+	table := flag.Bool("table", true,
+		"when output goes to a terminal, print records as aligned columns with colored mediums")
+	ratingScale := flag.String("rating-scale", "",
+		"rate records from min to max in steps, as min-max/step, and move loaded ratings to it")
+	duplicateTitles := flag.Bool("duplicate-titles", false,
+		"allow records to share a title, such as editions on different mediums")
+	foldedTitles := flag.Bool("case-insensitive-titles", false,
+		"refuse titles that differ from the title of another record only in case")
+	ignoreArticles := flag.String("ignore-articles", "",
+		"sort titles without leading articles of these comma-separated languages ("+
+			strings.Join(Languages(), ", ")+") or words")
+	user := flag.String("user", "",
+		"give ratings as this user, or as no user in particular if empty")
+	flag.Parse()
+
+	var articles []string
+
+	if *ignoreArticles != "" {
+		var err error
+
+		if articles, err = ParseArticles(*ignoreArticles); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	switch flag.Arg(0) {
+	case "replay":
+		os.Exit(replayMain(flag.Args()[1:], os.Stdout))
+	}
+
+	session := NewSession()
+	session.interactive = IsTerminal(os.Stdin)
+	session.terminal = IsTerminal(os.Stdout)
+	session.suggest = *suggest
+	session.pager = *pager
+
+	if *user != "" && !isUserName(*user) {
+		fmt.Fprintf(os.Stderr, "invalid user %q, expected one word other than %q\n", *user, unattributedName)
+		os.Exit(2)
+	}
+
+	session.user = *user
+	session.library.duplicateTitles = *duplicateTitles
+	session.library.foldedTitles = *foldedTitles
+	session.library.SetArticles(articles)
+
+	if *ratingScale != "" {
+		scale, err := ParseRatingScale(*ratingScale)
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+
+		session.ratingScale = &scale
+		session.library.Rescale(scale, session.catalog)
+	}
+
+	if *table && session.terminal {
+		session.table = NewTable(os.Stdout, os.Getenv("NO_COLOR") == "", session.library)
+	}
+
+	dispatcher := NewCommandDispatcher()
+
+	if *journalFilename != "" && *storeFilename != "" {
+		fmt.Fprintln(os.Stderr, "-journal and -store can't be used together")
+		os.Exit(2)
+	}
+
+	if *storeFilename != "" {
+		storage, err := OpenStorage(*storeFormat, *storeFilename)
+
+		if err == nil {
+			err = storage.Load(session)
+		}
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		defer storage.Close()
+		session.storage = storage
+
+		// the Storage already holds everything it restored
+		session.changes = Changes{}
+	}
+
+	if *journalFilename != "" {
+		journal, numRecovered, err := OpenJournal(*journalFilename, session, dispatcher)
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		defer journal.Close()
+
+		if numRecovered > 0 {
+			fmt.Printf("Recovered %d changes from journal\n", numRecovered)
+		}
+	}
+
+	if *autosaveChanges > 0 || *autosaveInterval > 0 {
+		autosave := NewAutosave(session, *autosaveFilename, *autosaveChanges)
+
+		if *autosaveInterval > 0 {
+			stop := autosave.Every(session, *autosaveInterval, os.Stderr)
+			defer stop()
+		}
+	}
+
+	dispatcher.Run(session, os.Stdin, os.Stdout)
+}
+
+func defaultPager() string {
+	if pager := os.Getenv("PAGER"); pager != "" {
+		return pager
+	}
+
+	return "less"
+}
+
+// NewCommandDispatcher creates a Dispatcher that knows every command
+func NewCommandDispatcher() *Dispatcher {
+	commands := map[string]Command{
 		"fr": findRecord,
 		"pr": printRecord,
 		"pc": printCollection,
 		"pL": printLibrary,
 		"pC": printCatalog,
 		"pa": printAllocations,
+		"sA": saveAll,
+		"rA": restoreAll,
+		"fs": findString,
+		"ff": findFuzzy,
+		"lr": listRatings,
+		"cs": collectionStatistics,
+		"st": printStatistics,
+		"cm": printMemberships,
+		"pt": printTree,
+		"pd": printDeep,
+		"cr": printContaining,
+		"lo": printLoans,
+		"od": printOverdue,
+		"lh": printLoanHistory,
+		"vh": printViewHistory,
+		"rw": printRecentlyViewed,
+		"nw": printNotViewed,
+		"rr": printRecordRatings,
+		"us": setUser,
+		"nd": printNearDuplicates,
+		"ps": setPageSize,
+		"np": nextPage,
+		"pp": previousPage,
+		"pj": jumpToPage,
+	}
+
+	mutatingCommands := map[string]Command{
 		"ar": addRecord,
 		"ac": addCollection,
 		"am": addMember,
@@ -49,459 +221,1453 @@ func main() {
 		"cL": clearLibrary,
 		"cC": clearCatalog,
 		"cA": clearAll,
-		"sA": saveAll,
-		"rA": restoreAll,
-		"fs": findString,
-		"lr": listRatings,
-		"cs": collectionStatistics,
 		"cc": combineCollections,
 		"mt": modifyTitle,
+		"ao": addUncollected,
+		"mc": renameCollection,
+		"cy": copyCollection,
+		"md": describeCollection,
+		"mo": modifyOwner,
+		"ms": modifySortOrder,
+		"im": insertMember,
+		"mm": moveMember,
+		"rc": reverseCollection,
+		"sc": shuffleCollection,
+		"sp": setParent,
+		"rp": removeParent,
+		"le": lendRecord,
+		"re": returnRecord,
+		"lv": logView,
+		"rs": rescaleRatings,
 	}
 
-	library := NewLibrary()
-	catalog := NewCatalog()
-	stdin = bufio.NewReader(os.Stdin)
-
-	for {
-		fmt.Print("\nEnter command: ")
-
-		cmd := readCommand()
-
-		if cmd == "qq" {
-			break
-		}
+	dispatcher := NewDispatcher()
 
-		if command, ok := commands[cmd]; !ok {
-			fmt.Println("Unrecognized command!")
-			ReadLine(stdin)
-		} else if e := command(library, catalog); e != nil {
-			if e.ShouldSkipNewline() {
-				ReadLine(stdin)
-			}
+	for name, command := range commands {
+		dispatcher.Register(name, command)
+	}
 
-			fmt.Println(e)
-		}
+	for name, command := range mutatingCommands {
+		dispatcher.RegisterMutating(name, command)
 	}
 
-	_ = clearAll(library, catalog)
-	fmt.Println("Done")
+	return dispatcher
 }
 
-func findRecord(library *Library, _ *Catalog) Error {
-	record, err := readRecordByTitle(library)
+func findRecord(session *Session, args *Args, out io.Writer) Error {
+	record, err := readRecordByTitle(session, args, out)
 
 	if err != nil {
 		return err
 	}
 
-	fmt.Println(record)
+	fmt.Fprintln(out, session.sprintRecords([]*Record{record}))
 
 	return nil
 }
 
-func printRecord(library *Library, _ *Catalog) Error {
-	record, err := readRecordByID(library)
+func printRecord(session *Session, args *Args, out io.Writer) Error {
+	record, err := readRecordByID(session.library, args)
 
 	if err != nil {
 		return err
 	}
 
-	fmt.Println(record)
+	fmt.Fprintln(out, session.sprintRecords([]*Record{record}))
 
 	return nil
 }
 
-func printCollection(_ *Library, catalog *Catalog) Error {
-	collection, err := readCollection(catalog)
+func printCollection(session *Session, args *Args, out io.Writer) Error {
+	collection, err := readCollection(session.catalog, args)
 
 	if err != nil {
 		return err
 	}
 
-	fmt.Println(collection)
+	if len(collection.members) == 0 {
+		fmt.Fprintln(out, collection)
+	} else {
+		showListing(session, out, NewCollectionListing(collection))
+	}
+
+	for _, line := range collection.Details() {
+		fmt.Fprintln(out, line)
+	}
 
 	return nil
 }
 
-func printLibrary(library *Library, _ *Catalog) Error {
-	fmt.Println(library)
+func printLibrary(session *Session, _ *Args, out io.Writer) Error {
+	if session.library.NumRecords() == 0 {
+		fmt.Fprintln(out, msgLibraryEmpty)
+
+		return nil
+	}
+
+	showListing(session, out, NewLibraryListing(session.library))
 
 	return nil
 }
 
-func printCatalog(_ *Library, catalog *Catalog) Error {
-	fmt.Println(catalog)
+func printCatalog(session *Session, _ *Args, out io.Writer) Error {
+	if session.catalog.NumCollections() == 0 {
+		fmt.Fprintln(out, session.catalog)
+
+		return nil
+	}
+
+	showListing(session, out, NewCatalogListing(session.catalog))
 
 	return nil
 }
 
-func printAllocations(library *Library, catalog *Catalog) Error {
+func printAllocations(session *Session, _ *Args, out io.Writer) Error {
 	fmtStr := `Memory allocations:
 Records: %d
 Collections: %d
 `
-	fmt.Printf(fmtStr, library.NumRecords(), catalog.NumCollections())
+	fmt.Fprintf(out, fmtStr, session.library.NumRecords(), session.catalog.NumCollections())
 
 	return nil
 }
 
-func addRecord(library *Library, _ *Catalog) Error {
-	medium := ReadWord(stdin)
-	title, err := readTitle()
+func addRecord(session *Session, args *Args, out io.Writer) Error {
+	medium := args.Word()
+	title, err := args.Title()
 
 	if err != nil {
 		return err
 	}
 
-	id, err := library.AddRecord(medium, title)
+	id, err := session.library.AddRecord(medium, title)
 
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Record %d added\n", id)
+	session.recordChanged(id)
+	fmt.Fprintf(out, "Record %d added\n", id)
 
 	return nil
 }
 
-func addCollection(_ *Library, catalog *Catalog) Error {
-	name := ReadWord(stdin)
-	err := catalog.AddCollection(name)
+func addCollection(session *Session, args *Args, out io.Writer) Error {
+	at := args.Time()
+	name := args.Word()
+	err := session.catalog.AddCollection(name, at)
 
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Collection %s added\n", name)
+	session.collectionChanged(name)
+	fmt.Fprintf(out, "Collection %s added\n", name)
 
 	return nil
 }
 
-func addMember(library *Library, catalog *Catalog) Error {
-	collection, err := readCollection(catalog)
+func addMember(session *Session, args *Args, out io.Writer) Error {
+	at := args.Time()
+	collection, err := readCollection(session.catalog, args)
 
 	if err != nil {
 		return err
 	}
 
-	record, err := readRecordByID(library)
+	record, err := readRecordByID(session.library, args)
 
 	if err != nil {
 		return err
 	}
 
-	err = collection.AddMember(record)
+	err = collection.AddMember(record, at)
 
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Member %d %s added\n", record.ID(), record.Title())
+	session.collectionChanged(collection.Name())
+	fmt.Fprintf(out, "Member %d %s added\n", record.ID(), record.Title())
 
 	return nil
 }
 
-func modifyRating(library *Library, _ *Catalog) Error {
-	record, err := readRecordByID(library)
+func insertMember(session *Session, args *Args, out io.Writer) Error {
+	at := args.Time()
+	collection, err := readCollection(session.catalog, args)
+
+	if err != nil {
+		return err
+	}
+
+	record, err := readRecordByID(session.library, args)
 
 	if err != nil {
 		return err
 	}
 
-	newRating, err := ReadInt(stdin)
+	position, err := args.Int()
 
 	if err != nil {
 		return err
 	}
 
-	err = record.SetRating(newRating)
+	err = collection.InsertMember(record, position, at)
 
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Rating for record %d changed to %d\n", record.ID(), newRating)
+	session.collectionChanged(collection.Name())
+	fmt.Fprintf(out, "Member %d %s inserted at position %d\n", record.ID(), record.Title(), position)
 
 	return nil
 }
 
-func deleteRecord(library *Library, _ *Catalog) Error {
-	title, err := readTitle()
+func moveMember(session *Session, args *Args, out io.Writer) Error {
+	at := args.Time()
+	collection, err := readCollection(session.catalog, args)
 
 	if err != nil {
 		return err
 	}
 
-	record, err := library.DeleteRecord(title)
+	record, err := readRecordByID(session.library, args)
 
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Record %d %s deleted\n", record.ID(), record.Title())
+	position, err := args.Int()
+
+	if err != nil {
+		return err
+	}
+
+	err = collection.MoveMember(record, position, at)
+
+	if err != nil {
+		return err
+	}
+
+	session.collectionChanged(collection.Name())
+	fmt.Fprintf(out, "Member %d %s moved to position %d\n", record.ID(), record.Title(), position)
 
 	return nil
 }
 
-func deleteCollection(_ *Library, catalog *Catalog) Error {
-	name := ReadWord(stdin)
-	err := catalog.DeleteCollection(name)
+func reverseCollection(session *Session, args *Args, out io.Writer) Error {
+	at := args.Time()
+	collection, err := readCollection(session.catalog, args)
+
+	if err != nil {
+		return err
+	}
+
+	err = collection.Reverse(at)
 
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Collection %s deleted\n", name)
+	session.collectionChanged(collection.Name())
+	fmt.Fprintf(out, "Collection %s reversed\n", collection.Name())
 
 	return nil
 }
 
-func deleteMember(library *Library, catalog *Catalog) Error {
-	collection, err := readCollection(catalog)
+func shuffleCollection(session *Session, args *Args, out io.Writer) Error {
+	at := args.Time()
+	collection, err := readCollection(session.catalog, args)
 
 	if err != nil {
 		return err
 	}
 
-	record, err := readRecordByID(library)
+	seed, err := args.Seed()
 
 	if err != nil {
 		return err
 	}
 
-	err = collection.DeleteMember(record)
+	err = collection.Shuffle(seed, at)
 
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Member %d %s deleted\n", record.ID(), record.Title())
+	session.collectionChanged(collection.Name())
+	fmt.Fprintf(out, "Collection %s shuffled\n", collection.Name())
 
 	return nil
 }
 
-func clearLibrary(library *Library, catalog *Catalog) Error {
-	err := library.Clear(catalog)
+func modifyRating(session *Session, args *Args, out io.Writer) Error {
+	record, err := readRecordByID(session.library, args)
 
 	if err != nil {
 		return err
 	}
 
-	fmt.Println("All records deleted")
+	newRating, err := args.Rating()
 
-	return nil
-}
+	if err != nil {
+		return err
+	}
 
-func clearCatalog(_ *Library, catalog *Catalog) Error {
-	catalog.Clear()
-	fmt.Println("All collections deleted")
+	// check before the user is read, so the rest of the line is skipped
+	if err := session.library.Scale().Check(newRating); err != nil {
+		return err
+	}
 
-	return nil
-}
+	user, err := args.User(session.user)
+
+	if err != nil {
+		return err
+	}
+
+	err = session.library.SetRating(record, user, newRating, session.catalog)
+
+	if err != nil {
+		return err
+	}
+
+	session.recordChanged(record.ID())
 
-func clearAll(library *Library, catalog *Catalog) Error {
-	library.ClearAll(catalog)
-	fmt.Println("All data deleted")
+	if user == unattributedUser {
+		fmt.Fprintf(out, "Rating for record %d changed to %s\n", record.ID(), newRating)
+	} else {
+		fmt.Fprintf(out, "Rating by %s for record %d changed to %s\n", user, record.ID(), newRating)
+	}
 
 	return nil
 }
 
-const errUnopenableFile = "Could not open file!"
-
-func saveAll(library *Library, catalog *Catalog) Error {
-	filename := ReadWord(stdin)
-	file, err := os.Create(filename)
+func deleteRecord(session *Session, args *Args, out io.Writer) Error {
+	record, err := readRecordByTitle(session, args, out)
 
 	if err != nil {
-		return NewlineError(errUnopenableFile)
+		return err
 	}
 
-	defer file.Close()
+	err = session.library.DeleteRecord(record)
 
-	library.Save(file)
-	catalog.Save(file)
+	if err != nil {
+		return err
+	}
 
-	fmt.Println("Data saved")
+	session.recordChanged(record.ID())
+	fmt.Fprintf(out, "Record %d %s deleted\n", record.ID(), record.Title())
 
 	return nil
 }
 
-func restoreAll(library *Library, catalog *Catalog) Error {
-	filename := ReadWord(stdin)
-	file, err := os.Open(filename)
+func deleteCollection(session *Session, args *Args, out io.Writer) Error {
+	at := args.Time()
+	collection, err := readCollection(session.catalog, args)
 
 	if err != nil {
-		return NewlineError(errUnopenableFile)
+		return err
 	}
 
-	defer file.Close()
-	reader := bufio.NewReader(file)
+	// the Collections inside it move to its parent
+	name := collection.Name()
+
+	for _, child := range collection.Children() {
+		session.collectionChanged(child.Name())
+	}
+
+	err = session.catalog.DeleteCollection(name, at)
+
+	if err != nil {
+		return err
+	}
+
+	session.collectionChanged(name)
+
+	fmt.Fprintf(out, "Collection %s deleted\n", name)
 
-	newLibrary, parseErr := RestoreLibrary(reader)
+	return nil
+}
 
-	if parseErr != nil {
-		return parseErr
+func deleteMember(session *Session, args *Args, out io.Writer) Error {
+	at := args.Time()
+	collection, err := readCollection(session.catalog, args)
+
+	if err != nil {
+		return err
 	}
 
-	newCatalog, parseErr := RestoreCatalog(reader, newLibrary)
+	record, err := readRecordByID(session.library, args)
 
-	if parseErr != nil {
-		return parseErr
+	if err != nil {
+		return err
 	}
 
-	*library = *newLibrary
-	*catalog = *newCatalog
+	err = collection.DeleteMember(record, at)
+
+	if err != nil {
+		return err
+	}
 
-	fmt.Println("Data loaded")
+	session.collectionChanged(collection.Name())
+	fmt.Fprintf(out, "Member %d %s deleted\n", record.ID(), record.Title())
 
 	return nil
 }
 
-func findString(library *Library, _ *Catalog) Error {
-	substr := ReadWord(stdin)
-	matches, err := library.FindString(substr)
+func clearLibrary(session *Session, _ *Args, out io.Writer) Error {
+	err := session.library.Clear(session.catalog)
 
 	if err != nil {
 		return err
 	}
 
-	fmt.Println(matches)
+	session.everythingChanged()
+
+	fmt.Fprintln(out, "All records deleted")
 
 	return nil
 }
 
-func listRatings(library *Library, _ *Catalog) Error {
-	fmt.Println(library.ListRatings())
+func clearCatalog(session *Session, _ *Args, out io.Writer) Error {
+	session.catalog.Clear()
+	session.everythingChanged()
+	fmt.Fprintln(out, "All collections deleted")
 
 	return nil
 }
 
-func collectionStatistics(library *Library, catalog *Catalog) Error {
-	numOne, numMany, total := catalog.CollectionStatistics()
-	numRecords := library.NumRecords()
+func clearAll(session *Session, _ *Args, out io.Writer) Error {
+	session.library.ClearAll(session.catalog)
+	session.everythingChanged()
+	fmt.Fprintln(out, "All data deleted")
 
-	// could use string concatenation instead here
-	fmtStr := `%d out of %d Records appear in at least one Collection
-%d out of %d Records appear in more than one Collection
-Collections contain a total of %d Records
-`
-	fmt.Printf(fmtStr, numOne, numRecords, numMany, numRecords, total)
+	return nil
+}
+
+const errUnopenableFile = "Could not open file!"
+
+func saveAll(session *Session, args *Args, out io.Writer) Error {
+	filename := args.Word()
+
+	if err := session.SaveFile(filename); err != nil {
+		return NewlineError(errUnopenableFile)
+	}
+
+	fmt.Fprintln(out, "Data saved")
 
 	return nil
 }
 
-func combineCollections(_ *Library, catalog *Catalog) Error {
-	firstSrc, err := readCollection(catalog)
+const errRestoreOverJournal = "Cannot restore another file over the journaled data file!"
+const errRestoreDiscardsJournal = "Cannot restore the journaled data file over changes journaled since it was saved!"
 
-	if err != nil {
-		return err
+func restoreAll(session *Session, args *Args, out io.Writer) Error {
+	filename := args.Word()
+
+	if session.dirty && session.interactive {
+		fmt.Fprint(out, "There are unsaved changes. Discard them? (y/n): ")
+
+		if args.Answer() != "y" {
+			return NewlineError("Restore cancelled!")
+		}
+	}
+
+	// the restored data becomes the journaled data file, so restoring any
+	// other file overwrites it
+	if session.journal != nil && !session.journal.Covers(filename) {
+		if !session.interactive {
+			return NewlineError(errRestoreOverJournal)
+		}
+
+		fmt.Fprintf(out, "This replaces the journaled data file %s. Continue? (y/n): ", session.journal.dataFilename)
+
+		if args.Answer() != "y" {
+			return NewlineError("Restore cancelled!")
+		}
+	} else if session.journal != nil && session.journal.NumEntries() > 0 {
+		// changes in the journal never count as unsaved, but restoring the
+		// data file they were journaled on top of discards them
+		if !session.interactive {
+			return NewlineError(errRestoreDiscardsJournal)
+		}
+
+		fmt.Fprintf(out, "This discards %d changes journaled since the last save. Continue? (y/n): ",
+			session.journal.NumEntries())
+
+		if args.Answer() != "y" {
+			return NewlineError("Restore cancelled!")
+		}
 	}
 
-	secondSrc, err := readCollection(catalog)
+	file, err := os.Open(filename)
 
 	if err != nil {
+		return NewlineError(errUnopenableFile)
+	}
+
+	defer file.Close()
+
+	if err := session.Restore(bufio.NewReader(file)); err != nil {
 		return err
 	}
 
-	dstName := ReadWord(stdin)
+	// the journal can't describe a restore, so snapshot the restored state
+	if session.journal != nil {
+		if err := session.journal.Compact(session); err != nil {
+			return RegularError(errUnwritableJournal)
+		}
+	} else if session.storage != nil {
+		if err := session.store(); err != nil {
+			return RegularError(errUnwritableStorage)
+		}
+	}
+
+	fmt.Fprintln(out, "Data loaded")
+
+	return nil
+}
 
-	err = catalog.CombineCollections(firstSrc, secondSrc, dstName)
+const errNoMatchingRecords = "No records contain that string!"
 
-	if err != nil {
-		return err
+// findString searches titles for a word, or in a mode chosen by a leading
+// option: -p for the rest of the line as a phrase, -r for a regular
+// expression, -w for a whole word, -a for all of several words, or -o for any
+// of several words
+func findString(session *Session, args *Args, out io.Writer) Error {
+	word := args.Word()
+	consumedLine := true
+
+	var matcher *TitleMatcher
+
+	switch word {
+	case "-p":
+		phrase, err := args.Title()
+
+		if err != nil {
+			return err
+		}
+
+		matcher = NewSubstringMatcher(phrase)
+	case "-r":
+		expr := strings.TrimSpace(args.Line())
+		var err error
+
+		if matcher, err = NewRegexpMatcher(expr); err != nil || expr == "" {
+			return RegularError("Could not read a regular expression!")
+		}
+	case "-w":
+		matcher = NewWordMatcher(normalizeTitle(args.Word()))
+		consumedLine = false
+	case "-a", "-o":
+		terms := strings.Fields(normalizeTitle(args.Line()))
+
+		if len(terms) == 0 {
+			return RegularError("Could not read any search terms!")
+		}
+
+		matcher = NewTermsMatcher(terms, word == "-a")
+	default:
+		matcher = NewSubstringMatcher(normalizeTitle(word))
+		consumedLine = false
 	}
 
-	fmt.Printf("Collections %s and %s combined into new collection %s\n",
-		firstSrc.Name(), secondSrc.Name(), dstName)
+	results := session.library.Search(matcher)
+
+	if len(results) == 0 {
+		if consumedLine {
+			return RegularError(errNoMatchingRecords)
+		}
+
+		return NewlineError(errNoMatchingRecords)
+	}
+
+	fmt.Fprintln(out, SprintSearchResults(results, session.terminal, session.table))
 
 	return nil
 }
 
-func modifyTitle(library *Library, _ *Catalog) Error {
-	record, err := readRecordByID(library)
+const numFuzzyMatches = 10
+
+func findFuzzy(session *Session, args *Args, out io.Writer) Error {
+	query, err := args.Title()
 
 	if err != nil {
 		return err
 	}
 
-	newTitle, err := readTitle()
+	matches := session.library.FindFuzzy(query, numFuzzyMatches)
 
-	if err != nil {
-		return err
+	if len(matches) == 0 {
+		return RegularError("No records resemble that title!")
 	}
 
-	err = library.ModifyTitle(record, newTitle)
+	fmt.Fprintln(out, session.sprintRecords(matches))
 
-	if err != nil {
-		return err
+	return nil
+}
+
+func listRatings(session *Session, _ *Args, out io.Writer) Error {
+	if session.library.NumRecords() == 0 {
+		fmt.Fprintln(out, msgLibraryEmpty)
+
+		return nil
 	}
 
-	fmt.Printf("Title for record %d changed to %s\n", record.ID(), newTitle)
+	showListing(session, out, NewRatingsListing(session.library))
 
 	return nil
 }
 
-func readRecordByTitle(library *Library) (*Record, Error) {
-	title, err := readTitle()
+func setPageSize(session *Session, args *Args, out io.Writer) Error {
+	size, err := args.Int()
 
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	if size < 0 {
+		return NewlineError("Page size is out of range!")
+	}
+
+	session.pageSize = size
+
+	if session.listing != nil && size > 0 {
+		session.listing.start -= session.listing.start % size
 	}
 
-	return library.FindRecordByTitle(title)
+	if size == 0 {
+		fmt.Fprintln(out, "Paging disabled")
+	} else {
+		fmt.Fprintf(out, "Page size set to %d\n", size)
+	}
+
+	return nil
 }
 
-func readRecordByID(library *Library) (*Record, Error) {
-	id, err := ReadInt(stdin)
+func nextPage(session *Session, _ *Args, out io.Writer) Error {
+	if err := checkPaging(session); err != nil {
+		return err
+	}
 
-	if err != nil {
-		return nil, err
+	if !session.listing.Next(session.pageSize) {
+		return NewlineError("Already on the last page!")
 	}
 
-	return library.FindRecordByID(id)
+	printLong(session, out, session.listing.Render(session.pageSize, session.table))
+
+	return nil
 }
 
-func readCollection(catalog *Catalog) (*Collection, Error) {
-	name := ReadWord(stdin)
+func previousPage(session *Session, _ *Args, out io.Writer) Error {
+	if err := checkPaging(session); err != nil {
+		return err
+	}
+
+	if !session.listing.Previous(session.pageSize) {
+		return NewlineError("Already on the first page!")
+	}
+
+	printLong(session, out, session.listing.Render(session.pageSize, session.table))
 
-	return catalog.FindCollection(name)
+	return nil
 }
 
-func readTitle() (string, Error) {
-	line := ReadLine(stdin)
-	fields := strings.Fields(line)
+func jumpToPage(session *Session, args *Args, out io.Writer) Error {
+	if err := checkPaging(session); err != nil {
+		return err
+	}
+
+	prefix := args.Word()
 
-	if len(fields) == 0 {
-		return "", RegularError("Could not read a title!")
+	if !session.listing.Jump(prefix, session.pageSize) {
+		return NewlineError("Cannot jump in this listing!")
 	}
 
-	var title strings.Builder
+	printLong(session, out, session.listing.Render(session.pageSize, session.table))
 
-	title.WriteString(fields[0])
+	return nil
+}
 
-	for _, word := range fields[1:] {
-		title.WriteRune(' ')
-		title.WriteString(word)
+func checkPaging(session *Session) Error {
+	if session.pageSize <= 0 {
+		return NewlineError("Paging is disabled!")
+	} else if session.listing == nil {
+		return NewlineError("No listing to page through!")
 	}
 
-	return title.String(), nil
+	return nil
+}
+
+// showListing prints the first page of a Listing and remembers it for np, pp
+// and pj
+func showListing(session *Session, out io.Writer, listing *Listing) {
+	session.listing = listing
+	printLong(session, out, listing.Render(session.pageSize, session.table))
 }
 
-func readCommand() string {
-	const commandLength = 2
+// printLong prints text that may not fit on the screen, through the Session's
+// pager if it is too tall for the terminal
+func printLong(session *Session, out io.Writer, text string) {
+	file, ok := out.(*os.File)
 
-	var command strings.Builder
+	if !ok || !session.terminal || session.pager == "" {
+		fmt.Fprintln(out, text)
 
-	for i := 0; i < commandLength; i++ {
-		SkipWhitespace(stdin)
-		r, _, err := stdin.ReadRune()
+		return
+	}
 
-		if err != nil {
-			panic(err)
-		}
+	// leave room for the next prompt
+	if height, _ := TerminalSize(file); height <= 0 || strings.Count(text, "\n")+2 < height {
+		fmt.Fprintln(out, text)
 
-		command.WriteRune(r)
+		return
 	}
 
-	return command.String()
+	pager := exec.Command("sh", "-c", session.pager)
+	pager.Stdin = strings.NewReader(text + "\n")
+	pager.Stdout = file
+	pager.Stderr = os.Stderr
+
+	if err := pager.Run(); err != nil {
+		fmt.Fprintln(out, text)
+	}
+}
+
+func printStatistics(session *Session, args *Args, out io.Writer) Error {
+	format := strings.TrimSpace(args.Line())
+	stats := ComputeStatistics(session.library, session.catalog)
+
+	switch format {
+	case "":
+		fmt.Fprintln(out, stats)
+	case "json":
+		fmt.Fprintln(out, stats.JSON())
+	default:
+		return RegularError("Unrecognized statistics format!")
+	}
+
+	return nil
+}
+
+func printTree(session *Session, _ *Args, out io.Writer) Error {
+	printLong(session, out, session.catalog.Tree())
+
+	return nil
+}
+
+func printDeep(session *Session, args *Args, out io.Writer) Error {
+	collection, err := readCollection(session.catalog, args)
+
+	if err != nil {
+		return err
+	}
+
+	listing := NewDeepListing(collection)
+
+	if listing.length == 0 {
+		fmt.Fprintf(out, fmtDeepHeader+" None\n", collection.Name())
+
+		return nil
+	}
+
+	showListing(session, out, listing)
+
+	return nil
+}
+
+func printContaining(session *Session, args *Args, out io.Writer) Error {
+	record, err := readRecordByID(session.library, args)
+
+	if err != nil {
+		return err
+	}
+
+	containing := session.catalog.CollectionsContaining(record)
+
+	fmt.Fprintf(out, "Record %d is in:", record.ID())
+
+	if len(containing) == 0 {
+		fmt.Fprintln(out, " None")
+
+		return nil
+	}
+
+	for _, collection := range containing {
+		if _, ok := collection.members[record.ID()]; ok {
+			fmt.Fprintf(out, "\n%s", collection.Name())
+		} else {
+			fmt.Fprintf(out, "\n%s (through a collection inside it)", collection.Name())
+		}
+	}
+
+	fmt.Fprintln(out)
+
+	return nil
+}
+
+func setParent(session *Session, args *Args, out io.Writer) Error {
+	at := args.Time()
+	collection, err := readCollection(session.catalog, args)
+
+	if err != nil {
+		return err
+	}
+
+	parent, err := readCollection(session.catalog, args)
+
+	if err != nil {
+		return err
+	}
+
+	err = session.catalog.SetParent(collection, parent, at)
+
+	if err != nil {
+		return err
+	}
+
+	session.collectionChanged(collection.Name())
+	fmt.Fprintf(out, "Collection %s moved inside collection %s\n", collection.Name(), parent.Name())
+
+	return nil
+}
+
+func removeParent(session *Session, args *Args, out io.Writer) Error {
+	at := args.Time()
+	collection, err := readCollection(session.catalog, args)
+
+	if err != nil {
+		return err
+	}
+
+	err = session.catalog.SetParent(collection, nil, at)
+
+	if err != nil {
+		return err
+	}
+
+	session.collectionChanged(collection.Name())
+	fmt.Fprintf(out, "Collection %s moved to the top of the catalog\n", collection.Name())
+
+	return nil
+}
+
+func lendRecord(session *Session, args *Args, out io.Writer) Error {
+	record, err := readRecordByID(session.library, args)
+
+	if err != nil {
+		return err
+	}
+
+	lent, err := args.Date()
+
+	if err != nil {
+		return err
+	}
+
+	due, err := args.Date()
+
+	if err != nil {
+		return err
+	}
+
+	borrower := args.Text()
+
+	if borrower == "" {
+		return RegularError("Could not read a borrower!")
+	}
+
+	err = record.Lend(borrower, lent, due)
+
+	if err != nil {
+		return err
+	}
+
+	session.recordChanged(record.ID())
+	fmt.Fprintf(out, "Record %d lent to %s until %s\n", record.ID(), borrower, due.Format(fmtDate))
+
+	return nil
+}
+
+func returnRecord(session *Session, args *Args, out io.Writer) Error {
+	record, err := readRecordByID(session.library, args)
+
+	if err != nil {
+		return err
+	}
+
+	returned, err := args.Date()
+
+	if err != nil {
+		return err
+	}
+
+	err = record.Return(returned)
+
+	if err != nil {
+		return err
+	}
+
+	session.recordChanged(record.ID())
+	fmt.Fprintf(out, "Record %d returned\n", record.ID())
+
+	return nil
+}
+
+func printLoans(session *Session, _ *Args, out io.Writer) Error {
+	printOnLoan(session, out, "Records on loan:", session.library.OnLoan(time.Time{}))
+
+	return nil
+}
+
+func printOverdue(session *Session, _ *Args, out io.Writer) Error {
+	printOnLoan(session, out, "Records overdue:", session.library.OnLoan(today()))
+
+	return nil
+}
+
+func printOnLoan(session *Session, out io.Writer, header string, records []*Record) {
+	if len(records) == 0 {
+		fmt.Fprintln(out, header+" None")
+
+		return
+	}
+
+	printLong(session, out, header+"\n"+session.sprintRecords(records))
+}
+
+func printLoanHistory(session *Session, args *Args, out io.Writer) Error {
+	record, err := readRecordByID(session.library, args)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Loans of record %d:", record.ID())
+
+	if len(record.Loans()) == 0 {
+		fmt.Fprintln(out, " None")
+
+		return nil
+	}
+
+	for _, loan := range record.Loans() {
+		fmt.Fprintf(out, "\n%s", loan)
+	}
+
+	fmt.Fprintln(out)
+
+	return nil
+}
+
+func logView(session *Session, args *Args, out io.Writer) Error {
+	record, err := readRecordByID(session.library, args)
+
+	if err != nil {
+		return err
+	}
+
+	date, err := args.Date()
+
+	if err != nil {
+		return err
+	}
+
+	rating, err := args.Rating()
+
+	if err != nil {
+		return err
+	}
+
+	// check before the note is read, so the rest of the line is skipped
+	if err := session.library.Scale().Check(rating); err != nil {
+		return err
+	}
+
+	err = record.AddView(date, rating, args.Text(), session.library.Scale())
+
+	if err != nil {
+		return err
+	}
+
+	session.recordChanged(record.ID())
+	fmt.Fprintf(out, "View of record %d on %s logged\n", record.ID(), date.Format(fmtDate))
+
+	return nil
+}
+
+func rescaleRatings(session *Session, args *Args, out io.Writer) Error {
+	scale, err := ParseRatingScale(args.Word())
+
+	if err != nil {
+		return NewlineError("Could not read a rating scale!")
+	}
+
+	session.library.Rescale(scale, session.catalog)
+	session.everythingChanged()
+
+	if session.ratingScale != nil {
+		session.ratingScale = &scale
+	}
+
+	fmt.Fprintf(out, "Ratings moved to scale %s\n", scale)
+
+	return nil
+}
+
+func printViewHistory(session *Session, args *Args, out io.Writer) Error {
+	record, err := readRecordByID(session.library, args)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Views of record %d:", record.ID())
+
+	if len(record.Views()) == 0 {
+		fmt.Fprintln(out, " None")
+
+		return nil
+	}
+
+	for _, view := range record.Views() {
+		fmt.Fprintf(out, "\n%s", view)
+	}
+
+	fmt.Fprintln(out)
+
+	return nil
+}
+
+func printRecentlyViewed(session *Session, args *Args, out io.Writer) Error {
+	limit, err := args.Int()
+
+	if err != nil {
+		return err
+	}
+
+	if limit < 0 {
+		return NewlineError("Number of records is out of range!")
+	}
+
+	printViewed(session, out, "Recently viewed:", session.library.RecentlyViewed(limit))
+
+	return nil
+}
+
+func printNotViewed(session *Session, args *Args, out io.Writer) Error {
+	years, err := args.Int()
+
+	if err != nil {
+		return err
+	}
+
+	if years < 0 {
+		return NewlineError("Number of years is out of range!")
+	}
+
+	printViewed(session, out, fmt.Sprintf("Not viewed in %d years:", years),
+		session.library.NotViewedSince(today().AddDate(-years, 0, 0)))
+
+	return nil
+}
+
+// printViewed prints Records, each followed by when it was last viewed
+func printViewed(session *Session, out io.Writer, header string, records []*Record) {
+	if len(records) == 0 {
+		fmt.Fprintln(out, header+" None")
+
+		return
+	}
+
+	lines := strings.Split(session.sprintRecords(records), "\n")
+
+	for i, record := range records {
+		lines[i] += " " + lastViewedNote(record)
+	}
+
+	printLong(session, out, header+"\n"+strings.Join(lines, "\n"))
+}
+
+func collectionStatistics(session *Session, _ *Args, out io.Writer) Error {
+	numOne, numMany, total := session.catalog.CollectionStatistics()
+	numRecords := session.library.NumRecords()
+
+	// could use string concatenation instead here
+	fmtStr := `%d out of %d Records appear in at least one Collection
+%d out of %d Records appear in more than one Collection
+Collections contain a total of %d Records
+`
+	fmt.Fprintf(out, fmtStr, numOne, numRecords, numMany, numRecords, total)
+
+	return nil
+}
+
+func printMemberships(session *Session, _ *Args, out io.Writer) Error {
+	uncollected := session.library.Uncollected()
+	shared := session.catalog.SharedRecords()
+
+	fmt.Fprint(out, "Records in no collection:")
+
+	if len(uncollected) == 0 {
+		fmt.Fprintln(out, " None")
+	} else {
+		fmt.Fprintf(out, "\n%s\n", session.sprintRecords(uncollected))
+	}
+
+	fmt.Fprint(out, "Records in more than one collection:")
+
+	if len(shared) == 0 {
+		fmt.Fprintln(out, " None")
+
+		return nil
+	}
+
+	records := make([]*Record, len(shared))
+
+	for i, sharedRecord := range shared {
+		records[i] = sharedRecord.record
+	}
+
+	for i, line := range strings.Split(session.sprintRecords(records), "\n") {
+		fmt.Fprintf(out, "\n%s (in %s)", line, strings.Join(shared[i].names, ", "))
+	}
+
+	fmt.Fprintln(out)
+
+	return nil
+}
+
+func addUncollected(session *Session, args *Args, out io.Writer) Error {
+	at := args.Time()
+	collection, err := readCollection(session.catalog, args)
+
+	if err != nil {
+		return err
+	}
+
+	uncollected := session.library.Uncollected()
+
+	if len(uncollected) == 0 {
+		return NewlineError("Every record is already in a collection!")
+	}
+
+	for _, record := range uncollected {
+		_ = collection.AddMember(record, at)
+	}
+
+	session.collectionChanged(collection.Name())
+	fmt.Fprintf(out, "%d records added to collection %s\n", len(uncollected), collection.Name())
+
+	return nil
+}
+
+func combineCollections(session *Session, args *Args, out io.Writer) Error {
+	at := args.Time()
+	firstSrc, err := readCollection(session.catalog, args)
+
+	if err != nil {
+		return err
+	}
+
+	secondSrc, err := readCollection(session.catalog, args)
+
+	if err != nil {
+		return err
+	}
+
+	dstName := args.Word()
+
+	err = session.catalog.CombineCollections(firstSrc, secondSrc, dstName, at)
+
+	if err != nil {
+		return err
+	}
+
+	session.collectionChanged(dstName)
+	fmt.Fprintf(out, "Collections %s and %s combined into new collection %s\n",
+		firstSrc.Name(), secondSrc.Name(), dstName)
+
+	return nil
+}
+
+func renameCollection(session *Session, args *Args, out io.Writer) Error {
+	at := args.Time()
+	collection, err := readCollection(session.catalog, args)
+
+	if err != nil {
+		return err
+	}
+
+	oldName := collection.Name()
+	newName := args.Word()
+	err = session.catalog.RenameCollection(collection, newName, at)
+
+	if err != nil {
+		return err
+	}
+
+	// the Collections inside it name it as their parent
+	session.collectionChanged(oldName)
+	session.collectionChanged(newName)
+
+	for _, child := range collection.Children() {
+		session.collectionChanged(child.Name())
+	}
+
+	fmt.Fprintf(out, "Collection %s renamed to %s\n", oldName, newName)
+
+	return nil
+}
+
+func copyCollection(session *Session, args *Args, out io.Writer) Error {
+	at := args.Time()
+	src, err := readCollection(session.catalog, args)
+
+	if err != nil {
+		return err
+	}
+
+	dstName := args.Word()
+	err = session.catalog.CopyCollection(src, dstName, at)
+
+	if err != nil {
+		return err
+	}
+
+	session.collectionChanged(dstName)
+	fmt.Fprintf(out, "Collection %s copied to new collection %s\n", src.Name(), dstName)
+
+	return nil
+}
+
+func describeCollection(session *Session, args *Args, out io.Writer) Error {
+	at := args.Time()
+	collection, err := readCollection(session.catalog, args)
+
+	if err != nil {
+		return err
+	}
+
+	collection.SetDescription(args.Text(), at)
+	session.collectionChanged(collection.Name())
+
+	if collection.description == "" {
+		fmt.Fprintf(out, "Description for collection %s removed\n", collection.Name())
+	} else {
+		fmt.Fprintf(out, "Description for collection %s changed to %s\n", collection.Name(), collection.description)
+	}
+
+	return nil
+}
+
+func modifyOwner(session *Session, args *Args, out io.Writer) Error {
+	at := args.Time()
+	collection, err := readCollection(session.catalog, args)
+
+	if err != nil {
+		return err
+	}
+
+	collection.SetOwner(args.Text(), at)
+	session.collectionChanged(collection.Name())
+
+	if collection.owner == "" {
+		fmt.Fprintf(out, "Owner for collection %s removed\n", collection.Name())
+	} else {
+		fmt.Fprintf(out, "Owner for collection %s changed to %s\n", collection.Name(), collection.owner)
+	}
+
+	return nil
+}
+
+func modifySortOrder(session *Session, args *Args, out io.Writer) Error {
+	at := args.Time()
+	collection, err := readCollection(session.catalog, args)
+
+	if err != nil {
+		return err
+	}
+
+	err = collection.SetSortBy(args.Word(), at)
+
+	if err != nil {
+		return err
+	}
+
+	session.collectionChanged(collection.Name())
+	fmt.Fprintf(out, "Collection %s now sorted by %s\n", collection.Name(), collection.sortBy)
+
+	return nil
+}
+
+func modifyTitle(session *Session, args *Args, out io.Writer) Error {
+	record, err := readRecordByID(session.library, args)
+
+	if err != nil {
+		return err
+	}
+
+	newTitle, err := args.Title()
+
+	if err != nil {
+		return err
+	}
+
+	err = session.library.ModifyTitle(record, newTitle, session.catalog)
+
+	if err != nil {
+		return err
+	}
+
+	session.recordChanged(record.ID())
+
+	// Collections sorted by title save their members in a new order
+	for name, collection := range session.catalog.collections {
+		if _, ok := collection.members[record.ID()]; ok {
+			session.collectionChanged(name)
+		}
+	}
+
+	fmt.Fprintf(out, "Title for record %d changed to %s\n", record.ID(), newTitle)
+
+	return nil
+}
+
+// readRecordByTitle reads a title, which may be qualified, and finds the
+// Record with it. If several Records have the title and a person is typing
+// commands, they are asked which one they mean.
+func readRecordByTitle(session *Session, args *Args, out io.Writer) (*Record, Error) {
+	title, err := args.Title()
+
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := session.library.FindRecordsByTitle(title)
+
+	if err != nil {
+		return nil, suggestTitles(session, title, err)
+	} else if len(records) == 1 {
+		args.Qualify(session.library, records[0])
+
+		return records[0], nil
+	} else if !session.interactive {
+		return nil, RegularError(errAmbiguousRecordTitle)
+	}
+
+	fmt.Fprintf(out, "Several records have that title:\n%s\nEnter the ID of one: ", session.sprintRecords(records))
+	answer := args.Answer()
+
+	for _, record := range records {
+		if answer == strconv.Itoa(record.id) {
+			args.Qualify(session.library, record)
+
+			return record, nil
+		}
+	}
+
+	return nil, NewlineError("No record chosen!")
+}
+
+const numSuggestions = 3
+
+// suggestTitles adds the titles most similar to one that no Record has to an
+// error, if the Session asks for suggestions
+func suggestTitles(session *Session, title string, err Error) Error {
+	if !session.suggest || err.Error() != errNoSuchRecordTitle {
+		return err
+	}
+
+	matches := session.library.FindFuzzy(title, numSuggestions)
+
+	if len(matches) == 0 {
+		return err
+	}
+
+	return RegularError(fmt.Sprintf("%s Did you mean:\n%s", err, session.sprintRecords(matches)))
+}
+
+func readRecordByID(library *Library, args *Args) (*Record, Error) {
+	id, err := args.Int()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return library.FindRecordByID(id)
+}
+
+func readCollection(catalog *Catalog, args *Args) (*Collection, Error) {
+	name := args.Word()
+
+	return catalog.FindCollection(name)
+}
+
+func printRecordRatings(session *Session, args *Args, out io.Writer) Error {
+	record, err := readRecordByID(session.library, args)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Ratings of record %d:", record.ID())
+
+	users, ratings := record.Ratings()
+
+	if len(users) == 0 {
+		fmt.Fprintln(out, " None")
+
+		return nil
+	}
+
+	for i, user := range users {
+		fmt.Fprintf(out, "\n  %s: %s", userName(user), ratings[i])
+	}
+
+	summary := record.Summary()
+	fmt.Fprintf(out, "\nMean %s, median %s, disagreement %s\n", summary.Mean, summary.Median, summary.Disagreement)
+
+	return nil
+}
+
+func setUser(session *Session, args *Args, out io.Writer) Error {
+	user := strings.TrimSpace(args.Line())
+
+	if user == unattributedName {
+		session.user = unattributedUser
+	} else if user != "" && !isUserName(user) {
+		return RegularError("Could not read a user!")
+	} else if user != "" {
+		session.user = user
+	}
+
+	if session.user == unattributedUser {
+		fmt.Fprintln(out, "Rating as no user")
+	} else {
+		fmt.Fprintf(out, "Rating as %s\n", session.user)
+	}
+
+	return nil
+}
+
+func printNearDuplicates(session *Session, args *Args, out io.Writer) Error {
+	groups := session.library.NearDuplicates()
+
+	fmt.Fprint(out, "Titles that differ only in case:")
+
+	if len(groups) == 0 {
+		fmt.Fprintln(out, " None")
+
+		return nil
+	}
+
+	for _, group := range groups {
+		fmt.Fprintf(out, "\n%s", session.sprintRecords(group))
+		fmt.Fprintln(out)
+	}
+
+	return nil
 }
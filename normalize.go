@@ -0,0 +1,190 @@
+// MIT License
+//
+// Copyright (c) 2019 Gregory Meyer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Hangul syllables are composed and decomposed arithmetically rather than
+// through the tables
+const (
+	hangulBase      = 0xAC00
+	hangulLeadBase  = 0x1100
+	hangulVowelBase = 0x1161
+	hangulTailBase  = 0x11A7
+	hangulLeads     = 19
+	hangulVowels    = 21
+	hangulTails     = 28
+	hangulSyllables = hangulLeads * hangulVowels * hangulTails
+)
+
+// canonicalCompositions maps a pair of runes to the rune they compose into
+var canonicalCompositions = func() map[[2]rune]rune {
+	compositions := make(map[[2]rune]rune)
+
+	for composed, pair := range canonicalDecompositions {
+		if pair[1] != 0 && !compositionExclusions[composed] {
+			compositions[pair] = composed
+		}
+	}
+
+	return compositions
+}()
+
+// normalizeTitle puts a title in Unicode Normalization Form C, so the same
+// title typed with precomposed letters or with combining accents is the same
+// string
+func normalizeTitle(title string) string {
+	if isASCII(title) {
+		return title
+	}
+
+	var decomposed []rune
+
+	for _, r := range title {
+		decomposed = decompose(decomposed, r)
+	}
+
+	orderCombiningMarks(decomposed)
+
+	return string(compose(decomposed))
+}
+
+// foldTitle returns the key under which titles that are the same once
+// normalized and compared case insensitively are equal
+func foldTitle(title string) string {
+	return strings.Map(foldRune, normalizeTitle(title))
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
+
+// decompose appends the full canonical decomposition of a rune to a slice
+func decompose(runes []rune, r rune) []rune {
+	if index := r - hangulBase; index >= 0 && index < hangulSyllables {
+		runes = append(runes, hangulLeadBase+index/(hangulVowels*hangulTails),
+			hangulVowelBase+index%(hangulVowels*hangulTails)/hangulTails)
+
+		if tail := index % hangulTails; tail != 0 {
+			runes = append(runes, hangulTailBase+tail)
+		}
+
+		return runes
+	}
+
+	pair, ok := canonicalDecompositions[r]
+
+	if !ok {
+		return append(runes, r)
+	}
+
+	runes = decompose(runes, pair[0])
+
+	if pair[1] != 0 {
+		runes = append(runes, pair[1])
+	}
+
+	return runes
+}
+
+// orderCombiningMarks sorts each run of combining marks by combining class,
+// keeping marks of the same class in order
+func orderCombiningMarks(runes []rune) {
+	for start := 0; start < len(runes); start++ {
+		end := start
+
+		for end < len(runes) && combiningClasses[runes[end]] != 0 {
+			end++
+		}
+
+		if end-start > 1 {
+			marks := runes[start:end]
+
+			sort.SliceStable(marks, func(i, j int) bool {
+				return combiningClasses[marks[i]] < combiningClasses[marks[j]]
+			})
+		}
+
+		start = end
+	}
+}
+
+// compose combines each starter with the marks that follow it, where nothing
+// between them blocks it, into precomposed runes
+func compose(runes []rune) []rune {
+	composed := runes[:0]
+	starter := -1
+	var lastClass uint8
+
+	for _, r := range runes {
+		class := combiningClasses[r]
+		adjacent := starter >= 0 && starter == len(composed)-1
+
+		if starter >= 0 && (adjacent || (lastClass != 0 && lastClass < class)) {
+			if combined, ok := composePair(composed[starter], r); ok {
+				composed[starter] = combined
+
+				continue
+			}
+		}
+
+		if class == 0 {
+			starter = len(composed)
+		}
+
+		lastClass = class
+		composed = append(composed, r)
+	}
+
+	return composed
+}
+
+// composePair returns the rune a starter and the rune after it compose into,
+// if they do
+func composePair(starter, r rune) (rune, bool) {
+	lead, vowel := starter-hangulLeadBase, r-hangulVowelBase
+
+	if lead >= 0 && lead < hangulLeads && vowel >= 0 && vowel < hangulVowels {
+		return hangulBase + (lead*hangulVowels+vowel)*hangulTails, true
+	}
+
+	syllable, tail := starter-hangulBase, r-hangulTailBase
+
+	if syllable >= 0 && syllable < hangulSyllables && syllable%hangulTails == 0 && tail > 0 && tail < hangulTails {
+		return starter + tail, true
+	}
+
+	composed, ok := canonicalCompositions[[2]rune{starter, r}]
+
+	return composed, ok
+}
@@ -0,0 +1,74 @@
+// MIT License
+//
+// Copyright (c) 2019 Gregory Meyer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"testing"
+)
+
+func TestNormalizeTitle(t *testing.T) {
+	for _, test := range []struct {
+		title, want string
+	}{
+		{"Alien", "Alien"},
+		{"Ame\u0301lie", "Am\u00E9lie"},
+		{"Am\u00E9lie", "Am\u00E9lie"},
+		// marks are put in canonical order before composing
+		{"a\u0302\u0323", "\u1EAD"},
+		{"a\u0323\u0302", "\u1EAD"},
+		// Hangul syllables are composed from their jamo
+		{"\u1112\u1161\u11AB\u1100\u1173\u11AF", "\uD55C\uAE00"},
+		// scripts other than Latin, Greek and Cyrillic
+		{"\u304B\u3099", "\u304C"},
+		{"\u05E9\u05C1", "\u05E9\u05C1"},
+		{"\u0B47\u0B3E", "\u0B4B"},
+		// singletons and composition exclusions never compose
+		{"\u212B", "\u00C5"},
+		{"\u0958", "\u0915\u093C"},
+		{"\u0F73", "\u0F71\u0F72"},
+		// a mark of the same class between them blocks a mark from composing
+		{"a\u0301\u0301", "\u00E1\u0301"},
+	} {
+		if got := normalizeTitle(test.title); got != test.want {
+			t.Errorf("normalizeTitle(%+q) = %+q, want %+q", test.title, got, test.want)
+		}
+	}
+}
+
+func TestFoldTitle(t *testing.T) {
+	if foldTitle("AME\u0301LIE") != foldTitle("am\u00E9lie") {
+		t.Errorf("foldTitle differs for titles that differ only in case and composition")
+	}
+}
+
+func TestFindStringNormalizesSearch(t *testing.T) {
+	session := NewSession()
+	dispatcher := NewCommandDispatcher()
+	runCommand(t, session, dispatcher, "ar DVD Am\u00E9lie")
+
+	for _, line := range []string{"fs me\u0301l", "fs -w ame\u0301lie", "fs -a me\u0301l"} {
+		if out := runCommand(t, session, dispatcher, line); out == "" {
+			t.Errorf("%+q found nothing", line)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+// MIT License
+//
+// Copyright (c) 2019 Gregory Meyer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"sort"
+)
+
+// RecordOrder is a slice of Records kept sorted as Records are inserted and
+// removed, so listing them never needs a sort. The order must be total, and a
+// Record must be removed before anything it is ordered by changes.
+type RecordOrder struct {
+	records []*Record
+	less    func(a, b *Record) bool
+}
+
+// NewRecordOrder creates an empty RecordOrder sorted by a less function
+func NewRecordOrder(less func(a, b *Record) bool) *RecordOrder {
+	return &RecordOrder{nil, less}
+}
+
+// newSortedRecordOrder creates a RecordOrder sorted by a less function from
+// Records in any order
+func newSortedRecordOrder(records []*Record, less func(a, b *Record) bool) *RecordOrder {
+	sorted := append([]*Record(nil), records...)
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return less(sorted[i], sorted[j])
+	})
+
+	return &RecordOrder{sorted, less}
+}
+
+// newFixedRecordOrder creates a RecordOrder of Records in the order they are
+// given in. Records must not be inserted into or removed from it.
+func newFixedRecordOrder(records []*Record) *RecordOrder {
+	return &RecordOrder{records, nil}
+}
+
+// Insert adds a Record in its sorted position
+func (o *RecordOrder) Insert(record *Record) {
+	i := o.search(record)
+
+	o.records = append(o.records, nil)
+	copy(o.records[i+1:], o.records[i:])
+	o.records[i] = record
+}
+
+// Remove takes a Record out of the order, if it is there
+func (o *RecordOrder) Remove(record *Record) {
+	i := o.search(record)
+
+	if i < len(o.records) && o.records[i] == record {
+		copy(o.records[i:], o.records[i+1:])
+		o.records[len(o.records)-1] = nil
+		o.records = o.records[:len(o.records)-1]
+	}
+}
+
+// Len returns the number of Records in the order
+func (o *RecordOrder) Len() int {
+	return len(o.records)
+}
+
+// Records returns every Record in order. The slice must not be modified.
+func (o *RecordOrder) Records() []*Record {
+	return o.records[:len(o.records):len(o.records)]
+}
+
+// Page returns up to count Records in order, starting from the Record at
+// position start. The slice must not be modified.
+func (o *RecordOrder) Page(start, count int) []*Record {
+	start = minInt(maxInt(start, 0), len(o.records))
+	end := minInt(start+maxInt(count, 0), len(o.records))
+
+	return o.records[start:end:end]
+}
+
+func (o *RecordOrder) search(record *Record) int {
+	return sort.Search(len(o.records), func(i int) bool {
+		return !o.less(o.records[i], record)
+	})
+}
+
+// TitleLess orders Records by title in ascending order, as collationKey orders
+// titles, then by ID
+func TitleLess(a, b *Record) bool {
+	if a.sortKey != b.sortKey {
+		return a.sortKey < b.sortKey
+	}
+
+	return a.id < b.id
+}
+
+// RatingLess orders Records by rating in descending order, with unrated
+// Records last, then by title in ascending order, then by ID
+func RatingLess(a, b *Record) bool {
+	if a.rating != b.rating {
+		return a.rating > b.rating
+	}
+
+	return TitleLess(a, b)
+}
+
+// insertString adds a string to a sorted slice of strings, keeping it sorted
+func insertString(sorted []string, s string) []string {
+	i := sort.SearchStrings(sorted, s)
+
+	sorted = append(sorted, "")
+	copy(sorted[i+1:], sorted[i:])
+	sorted[i] = s
+
+	return sorted
+}
+
+// removeString takes a string out of a sorted slice of strings, if it is there
+func removeString(sorted []string, s string) []string {
+	i := sort.SearchStrings(sorted, s)
+
+	if i < len(sorted) && sorted[i] == s {
+		sorted = append(sorted[:i], sorted[i+1:]...)
+	}
+
+	return sorted
+}
@@ -0,0 +1,206 @@
+// MIT License
+//
+// Copyright (c) 2019 Gregory Meyer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	fmtLibraryHeader    = "Library contains %d records:"
+	fmtCatalogHeader    = "Catalog contains %d collections:"
+	fmtCollectionHeader = "Collection %s contains:"
+	fmtDeepHeader       = "Collection %s and the collections inside it contain:"
+)
+
+// Listing is a sorted list of items that can be printed one page at a time
+type Listing struct {
+	// header is printed above every page, unless it is empty
+	header string
+
+	length int
+
+	// print returns the items from start to end, one string per item, as rows
+	// of a Table if it is not nil
+	print func(start, end int, table *Table) []string
+
+	// find returns the position of the first item whose key is not less than a
+	// prefix, or nil if the items can't be searched
+	find func(prefix string) int
+
+	// start is the position of the first item on the current page
+	start int
+}
+
+// NewLibraryListing lists the Records of a Library by title
+func NewLibraryListing(library *Library) *Listing {
+	return newRecordListing(fmt.Sprintf(fmtLibraryHeader, library.NumRecords()), library.inTitleOrder, true, nil)
+}
+
+// NewRatingsListing lists the Records of a Library by rating, with a summary of
+// the ratings of each Record rated by more than one user
+func NewRatingsListing(library *Library) *Listing {
+	return newRecordListing("", library.inRatingOrder, false, ratingNote)
+}
+
+// NewCollectionListing lists the members of a Collection by title, or by rating
+// if the Collection prefers it
+func NewCollectionListing(collection *Collection) *Listing {
+	return newRecordListing(fmt.Sprintf(fmtCollectionHeader, collection.name), collection.order(),
+		collection.sortBy == sortByTitle, nil)
+}
+
+// NewDeepListing lists the Records in a Collection or any Collection nested
+// inside it by title
+func NewDeepListing(collection *Collection) *Listing {
+	return newRecordListing(fmt.Sprintf(fmtDeepHeader, collection.name),
+		newFixedRecordOrder(collection.DeepMembers()), true, nil)
+}
+
+// NewCatalogListing lists the Collections of a Catalog by name
+func NewCatalogListing(catalog *Catalog) *Listing {
+	collections := catalog.sortedCollections()
+
+	print := func(start, end int, table *Table) []string {
+		items := make([]string, 0, end-start)
+
+		for _, collection := range collections[start:end] {
+			items = append(items, collection.render(table))
+		}
+
+		return items
+	}
+
+	find := func(prefix string) int {
+		return sort.SearchStrings(catalog.names, prefix)
+	}
+
+	return &Listing{fmt.Sprintf(fmtCatalogHeader, len(collections)), len(collections), print, find, 0}
+}
+
+// newRecordListing lists the Records of a RecordOrder, which can be searched by
+// title if byTitle is true. If note is not nil, it returns text to follow each
+// Record, or an empty string.
+func newRecordListing(header string, order *RecordOrder, byTitle bool, note func(*Record) string) *Listing {
+	print := func(start, end int, table *Table) []string {
+		records := order.Page(start, end-start)
+		var items []string
+
+		if table != nil {
+			items = table.Rows(records, nil)
+		} else {
+			items = make([]string, len(records))
+
+			for i, record := range records {
+				items[i] = record.String()
+			}
+		}
+
+		if note != nil {
+			for i, record := range records {
+				if text := note(record); text != "" {
+					items[i] += " " + text
+				}
+			}
+		}
+
+		return items
+	}
+
+	var find func(prefix string) int
+
+	if byTitle {
+		find = func(prefix string) int {
+			records := order.Records()
+			key := collationPrefix(prefix)
+
+			return sort.Search(len(records), func(i int) bool {
+				return records[i].sortKey >= key
+			})
+		}
+	}
+
+	return &Listing{header, order.Len(), print, find, 0}
+}
+
+// Render prints the current page of a Listing, or all of it if pageSize is not
+// positive, as rows of a Table if it is not nil. A footer says which page it
+// is if there is more than one.
+func (l *Listing) Render(pageSize int, table *Table) string {
+	var lines []string
+
+	if l.header != "" {
+		lines = append(lines, l.header)
+	}
+
+	if pageSize <= 0 {
+		return strings.Join(append(lines, l.print(0, l.length, table)...), "\n")
+	}
+
+	end := minInt(l.start+pageSize, l.length)
+	lines = append(lines, l.print(l.start, end, table)...)
+
+	if numPages := (l.length + pageSize - 1) / pageSize; numPages > 1 {
+		lines = append(lines, fmt.Sprintf("Page %d of %d", l.start/pageSize+1, numPages))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Next moves to the next page. Returns false if this is the last page.
+func (l *Listing) Next(pageSize int) bool {
+	if l.start+pageSize >= l.length {
+		return false
+	}
+
+	l.start += pageSize
+
+	return true
+}
+
+// Previous moves to the previous page. Returns false if this is the first
+// page.
+func (l *Listing) Previous(pageSize int) bool {
+	if l.start == 0 {
+		return false
+	}
+
+	l.start = maxInt(0, l.start-pageSize)
+
+	return true
+}
+
+// Jump moves to the page with the first item whose key is not less than a
+// prefix. Returns false if the items can't be searched.
+func (l *Listing) Jump(prefix string, pageSize int) bool {
+	if l.find == nil {
+		return false
+	}
+
+	position := minInt(l.find(prefix), maxInt(l.length-1, 0))
+	l.start = position - position%pageSize
+
+	return true
+}
@@ -0,0 +1,232 @@
+// MIT License
+//
+// Copyright (c) 2019 Gregory Meyer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Rating is a rating in hundredths of a point, so that ratings such as 4.5 or
+// 72.25 are stored exactly
+type Rating int
+
+// NoRating is the Rating of something that hasn't been rated
+const NoRating Rating = -1
+
+// ratingUnit is the Rating of one point
+const ratingUnit = 100
+
+// ParseRating reads a Rating written as a decimal number with at most two
+// digits after the point, or as "u" for NoRating
+func ParseRating(text string) (Rating, error) {
+	if text == "u" {
+		return NoRating, nil
+	}
+
+	whole, fraction := text, ""
+
+	if point := strings.IndexByte(text, '.'); point >= 0 {
+		whole, fraction = text[:point], text[point+1:]
+	}
+
+	if whole == "" || len(fraction) > 2 || strings.IndexFunc(whole+fraction, isNotDigit) >= 0 {
+		return 0, errors.New("not a rating")
+	}
+
+	points, err := strconv.Atoi(whole)
+
+	if err != nil || points > math.MaxInt32/ratingUnit {
+		return 0, errors.New("not a rating")
+	}
+
+	hundredths := 0
+
+	if fraction != "" {
+		hundredths, _ = strconv.Atoi((fraction + "0")[:2])
+	}
+
+	return Rating(points*ratingUnit + hundredths), nil
+}
+
+func isNotDigit(r rune) bool {
+	return r < '0' || r > '9'
+}
+
+// String prints a Rating with as few digits after the point as it needs, or
+// "u" for NoRating
+func (r Rating) String() string {
+	if r == NoRating {
+		return "u"
+	}
+
+	text := fmt.Sprintf("%d.%02d", r/ratingUnit, r%ratingUnit)
+
+	return strings.TrimSuffix(strings.TrimRight(text, "0"), ".")
+}
+
+// Float returns a Rating in points
+func (r Rating) Float() float64 {
+	return float64(r) / ratingUnit
+}
+
+// RatingScale is the set of Ratings that can be given: from Min to Max, in
+// increments of Step
+type RatingScale struct {
+	Min  Rating
+	Max  Rating
+	Step Rating
+}
+
+// legacyRatingScale is the scale of files saved before Ratings had scales,
+// where a rating of 0 meant unrated
+var legacyRatingScale = RatingScale{1 * ratingUnit, 5 * ratingUnit, ratingUnit}
+
+// defaultRatingScale allows half-star ratings out of five stars
+var defaultRatingScale = RatingScale{1 * ratingUnit, 5 * ratingUnit, ratingUnit / 2}
+
+// maxRatingValues is the most Ratings a RatingScale can have
+const maxRatingValues = 10001
+
+// ParseRatingScale reads a RatingScale written as "min-max" or "min-max/step",
+// such as "1-5/0.5" or "0-100". The step is 1 if it is left out.
+func ParseRatingScale(text string) (RatingScale, error) {
+	invalid := fmt.Errorf("invalid rating scale %q, expected min-max or min-max/step", text)
+
+	bounds, step := text, "1"
+
+	if slash := strings.IndexByte(text, '/'); slash >= 0 {
+		bounds, step = text[:slash], text[slash+1:]
+	}
+
+	dash := strings.IndexByte(bounds, '-')
+
+	if dash < 0 {
+		return RatingScale{}, invalid
+	}
+
+	var scale RatingScale
+	var errs [3]error
+
+	scale.Min, errs[0] = ParseRating(bounds[:dash])
+	scale.Max, errs[1] = ParseRating(bounds[dash+1:])
+	scale.Step, errs[2] = ParseRating(step)
+
+	for _, err := range errs {
+		if err != nil {
+			return RatingScale{}, invalid
+		}
+	}
+
+	if scale.Min == NoRating || scale.Max == NoRating || scale.Step == NoRating ||
+		scale.Max <= scale.Min || scale.Step <= 0 || (scale.Max-scale.Min)%scale.Step != 0 ||
+		(scale.Max-scale.Min)/scale.Step >= maxRatingValues {
+		return RatingScale{}, invalid
+	}
+
+	return scale, nil
+}
+
+// String prints a RatingScale the way ParseRatingScale reads it
+func (s RatingScale) String() string {
+	if s.Step == ratingUnit {
+		return fmt.Sprintf("%s-%s", s.Min, s.Max)
+	}
+
+	return fmt.Sprintf("%s-%s/%s", s.Min, s.Max, s.Step)
+}
+
+// Check returns an error if a Rating isn't on this RatingScale. NoRating is
+// on every RatingScale.
+func (s RatingScale) Check(rating Rating) Error {
+	if rating == NoRating {
+		return nil
+	}
+
+	if rating < s.Min || rating > s.Max {
+		return NewlineError("Rating is out of range!")
+	}
+
+	if (rating-s.Min)%s.Step != 0 {
+		return NewlineError(fmt.Sprintf("Rating must be a multiple of %s from %s!", s.Step, s.Min))
+	}
+
+	return nil
+}
+
+// Convert moves a Rating on another RatingScale to this one, keeping its place
+// between the minimum and the maximum, then rounding it to the nearest Rating
+// on this RatingScale. 3 on 1-5 is 50 on 0-100, or 5.5 on 1-10, which rounds
+// to 6.
+func (s RatingScale) Convert(rating Rating, from RatingScale) Rating {
+	if rating == NoRating || s == from {
+		return rating
+	}
+
+	fraction := float64(rating-from.Min) / float64(from.Max-from.Min)
+
+	return s.Nearest(s.Min + Rating(math.Round(fraction*float64(s.Max-s.Min))))
+}
+
+// Nearest returns the Rating on this RatingScale closest to a Rating, such as
+// the mean of several ratings
+func (s RatingScale) Nearest(rating Rating) Rating {
+	if rating == NoRating {
+		return rating
+	}
+
+	steps := math.Round(float64(rating-s.Min) / float64(s.Step))
+	nearest := s.Min + Rating(steps)*s.Step
+
+	if nearest < s.Min {
+		return s.Min
+	} else if nearest > s.Max {
+		return s.Max
+	}
+
+	return nearest
+}
+
+// Values returns every Rating on this RatingScale in descending order
+func (s RatingScale) Values() []Rating {
+	var values []Rating
+
+	for rating := s.Max; rating >= s.Min; rating -= s.Step {
+		values = append(values, rating)
+	}
+
+	return values
+}
+
+// halfStars returns a Rating as a number of half stars out of ten, so that it
+// can be drawn as five stars
+func (s RatingScale) halfStars(rating Rating) int {
+	if rating == NoRating {
+		return 0
+	}
+
+	return int(math.Round(rating.Float() / s.Max.Float() * 10))
+}
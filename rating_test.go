@@ -0,0 +1,80 @@
+// MIT License
+//
+// Copyright (c) 2019 Gregory Meyer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"testing"
+)
+
+func mustParseRatingScale(t *testing.T, text string) RatingScale {
+	t.Helper()
+
+	scale, err := ParseRatingScale(text)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return scale
+}
+
+func TestRatingScaleConvert(t *testing.T) {
+	for _, test := range []struct {
+		from, to     string
+		rating, want string
+	}{
+		{"1-5", "0-100", "3", "50"},
+		{"1-5", "1-10", "3", "6"},
+		{"1-5/0.5", "0-10", "1", "0"},
+		{"1-5/0.5", "0-10", "5", "10"},
+		{"1-5/0.5", "0-10", "4.5", "9"},
+		{"0-100", "1-5/0.5", "0", "1"},
+		{"0-100", "1-5/0.5", "100", "5"},
+		{"0-100", "1-5/0.5", "40", "2.5"},
+		{"1-10", "1-10", "7", "7"},
+		{"1-5", "0-100", "u", "u"},
+	} {
+		from, to := mustParseRatingScale(t, test.from), mustParseRatingScale(t, test.to)
+		rating, _ := ParseRating(test.rating)
+
+		if got := to.Convert(rating, from); got.String() != test.want {
+			t.Errorf("%s on %s is %s on %s, want %s", test.rating, test.from, got, test.to, test.want)
+		}
+	}
+}
+
+func TestRatingScaleConvertRoundTrip(t *testing.T) {
+	scales := []string{"1-5/0.5", "0-10", "1-10", "0-100", "1-5"}
+
+	for _, a := range scales {
+		for _, b := range scales {
+			from, to := mustParseRatingScale(t, a), mustParseRatingScale(t, b)
+
+			for _, end := range []Rating{from.Min, from.Max} {
+				if got := from.Convert(to.Convert(end, from), to); got != end {
+					t.Errorf("%s on %s came back from %s as %s", end, a, b, got)
+				}
+			}
+		}
+	}
+}
@@ -26,26 +26,40 @@ import (
 	"bufio"
 	"fmt"
 	"io"
-	"sort"
 	"strings"
+	"unicode"
 )
 
 // Record is a piece of media
 type Record struct {
 	medium         string
 	title          string
-	rating         int
 	id             int
 	numCollections int
+
+	// sortKey is the collationKey of the title, without the articles of the
+	// Library the Record is in
+	sortKey string
+
+	// rating is the mean of ratings, the rating each user gives this Record
+	rating  Rating
+	ratings map[string]Rating
+
+	// loans are the Loans of this Record, oldest first
+	loans []Loan
+
+	// views are the Views of this Record, oldest first
+	views []View
 }
 
-// NewRecord creates a Record
+// NewRecord creates a Record. The Library it is added to gives it its sortKey.
 func NewRecord(medium, title string, id int) *Record {
-	return &Record{medium, title, 0, id, 0}
+	return &Record{medium, title, id, 0, "", NoRating, nil, nil, nil}
 }
 
-// RestoreRecord deserializes a Record from a *bufio.Reader
-func RestoreRecord(reader *bufio.Reader) (*Record, Error) {
+// RestoreRecord deserializes a Record from a *bufio.Reader, with ratings on a
+// RatingScale. In a legacy file, ratings are integers and 0 means unrated.
+func RestoreRecord(reader *bufio.Reader, scale RatingScale, legacy bool) (*Record, Error) {
 	id, err := ReadInt(reader)
 
 	if err != nil || id < 1 {
@@ -59,20 +73,89 @@ func RestoreRecord(reader *bufio.Reader) (*Record, Error) {
 		return nil, NewlineError(ErrInvalidFile)
 	}
 
-	rating, err := ReadInt(reader)
+	rating, ok := readRating(reader, scale, legacy)
 
-	if err != nil || rating < 0 || rating > 5 {
+	if !ok {
 		return nil, NewlineError(ErrInvalidFile)
 	}
 
 	SkipWhitespace(reader)
-	title := ReadLine(reader)
+	title := readTitle(reader)
 
 	if len(title) == 0 {
 		return nil, NewlineError(ErrInvalidFile)
 	}
 
-	return &Record{medium, title, rating, id, 0}, nil
+	record := NewRecord(medium, title, id)
+
+	if err := record.restoreDetails(reader, scale); err != nil {
+		return nil, err
+	}
+
+	// the line of a Record rated by users holds the mean of their ratings,
+	// which need not be on the RatingScale, so only a rating given by no user
+	// in particular is read from it
+	if len(record.ratings) == 0 && record.SetRating(unattributedUser, rating, scale) != nil {
+		return nil, NewlineError(ErrInvalidFile)
+	}
+
+	return record, nil
+}
+
+// readRating reads the Rating of a Record saved by Save. Returns false if it
+// isn't a Rating.
+func readRating(reader *bufio.Reader, scale RatingScale, legacy bool) (Rating, bool) {
+	if legacy {
+		rating, err := ReadInt(reader)
+
+		if err != nil || rating < 0 || rating > int(legacyRatingScale.Max/ratingUnit) {
+			return 0, false
+		} else if rating == 0 {
+			return NoRating, true
+		}
+
+		return Rating(rating * ratingUnit), true
+	}
+
+	rating, err := ParseRating(ReadWord(reader))
+
+	if err != nil {
+		return 0, false
+	}
+
+	return rating, true
+}
+
+// restoreDetails reads the lines that follow the line of a Record, such as its
+// ratings, Loans and Views. Each one starts with a word saying what it holds, where the
+// line of the next Record starts with a number instead. Lines this version
+// doesn't know how to read are skipped.
+func (r *Record) restoreDetails(reader *bufio.Reader, scale RatingScale) Error {
+	for {
+		SkipWhitespace(reader)
+		next, _, err := reader.ReadRune()
+
+		if err != nil {
+			return nil
+		}
+
+		_ = reader.UnreadRune()
+
+		if !unicode.IsLetter(next) {
+			return nil
+		}
+
+		kind := ReadWord(reader)
+		line := ReadLine(reader)
+
+		if kind == "rating" && !r.restoreRating(line, scale) {
+			return NewlineError(ErrInvalidFile)
+		} else if kind == "loan" && !r.restoreLoan(line) {
+			return NewlineError(ErrInvalidFile)
+		} else if kind == "view" && !r.restoreView(line, scale) {
+			return NewlineError(ErrInvalidFile)
+		}
+	}
 }
 
 // ID gives the ID of this Record, which starts at 1 and goes up from there
@@ -86,36 +169,38 @@ func (r *Record) Title() string {
 	return r.title
 }
 
-// SetRating sets the rating of this Record
-// Ratings are between 1 and 5, inclusive
-func (r *Record) SetRating(newRating int) Error {
-	if newRating < 1 || newRating > 5 {
-		return NewlineError("Rating is out of range!")
-	}
-
-	r.rating = newRating
-
-	return nil
+// Rating gives the rating of this Record, the mean of the ratings users gave
+// it, or NoRating if it is unrated
+func (r *Record) Rating() Rating {
+	return r.rating
 }
 
 // Save serializes a Record to an io.Writer in a format suitable for recovery
 func (r *Record) Save(writer io.Writer) {
-	FprintfOrPanic(writer, "%d %s %d %s\n", r.id, r.medium, r.rating, r.title)
+	FprintfOrPanic(writer, "%d %s %s %s\n", r.id, r.medium, r.rating, r.title)
+	r.saveRatings(writer)
+	r.saveLoans(writer)
+	r.saveViews(writer)
 }
 
 func (r *Record) String() string {
-	if r.rating == 0 {
-		return fmt.Sprintf("%d: %s u %s", r.id, r.medium, r.title)
+	return r.stringWithTitle(r.title)
+}
+
+// stringWithTitle prints a Record with its title replaced, such as by one
+// with highlighting, followed by its loan status if it is on loan
+func (r *Record) stringWithTitle(title string) string {
+	if status := r.loanStatus(); status != "" {
+		title += " " + status
 	}
 
-	return fmt.Sprintf("%d: %s %d %s", r.id, r.medium, r.rating, r.title)
+	return fmt.Sprintf("%d: %s %s %s", r.id, r.medium, r.rating, title)
 }
 
-// SortRecordsByTitle sorts a slice of *Record by title in ascending order
-func SortRecordsByTitle(records []*Record) {
-	sort.Slice(records, func(i, j int) bool {
-		return records[i].title < records[j].title
-	})
+// readTitle reads the rest of a line as a title, compacting runs of
+// whitespace as Args.Title does, which older data files didn't
+func readTitle(reader *bufio.Reader) string {
+	return normalizeTitle(strings.Join(strings.Fields(ReadLine(reader)), " "))
 }
 
 // SprintRecords prints a slice of *Record to a string, each one on its own line
@@ -0,0 +1,160 @@
+// MIT License
+//
+// Copyright (c) 2019 Gregory Meyer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	transcriptInSuffix  = "_in.txt"
+	transcriptOutSuffix = "_out.txt"
+)
+
+// replayTime is what the clock reads while a transcript is replayed
+var replayTime = time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// replayMain runs each input transcript named by args against a fresh Session
+// and compares the output with the matching expected transcript. Returns the
+// process exit code.
+func replayMain(args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	flags.SetOutput(out)
+	update := flags.Bool("update", false, "overwrite expected transcripts with the actual output")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	inputs := flags.Args()
+
+	if len(inputs) == 0 {
+		matches, err := filepath.Glob(filepath.Join("samples", "*"+transcriptInSuffix))
+
+		if err != nil || len(matches) == 0 {
+			fmt.Fprintln(out, "No transcripts to replay!")
+
+			return 2
+		}
+
+		inputs = matches
+	}
+
+	status := 0
+
+	for _, input := range inputs {
+		if !strings.HasSuffix(input, transcriptInSuffix) {
+			fmt.Fprintf(out, "%s: transcript names must end in %s\n", input, transcriptInSuffix)
+			status = 1
+
+			continue
+		}
+
+		expectedName := strings.TrimSuffix(input, transcriptInSuffix) + transcriptOutSuffix
+		actual, err := replayTranscript(input)
+
+		if err != nil {
+			fmt.Fprintf(out, "%s: %v\n", input, err)
+			status = 1
+
+			continue
+		}
+
+		if *update {
+			if err := ioutil.WriteFile(expectedName, actual, 0644); err != nil {
+				fmt.Fprintf(out, "%s: %v\n", expectedName, err)
+				status = 1
+
+				continue
+			}
+
+			fmt.Fprintf(out, "updated %s\n", expectedName)
+
+			continue
+		}
+
+		expected, err := ioutil.ReadFile(expectedName)
+
+		if err != nil {
+			fmt.Fprintf(out, "%s: %v\n", expectedName, err)
+			status = 1
+
+			continue
+		}
+
+		if bytes.Equal(expected, actual) {
+			fmt.Fprintf(out, "ok   %s\n", input)
+
+			continue
+		}
+
+		fmt.Fprintf(out, "FAIL %s\n", input)
+		fmt.Fprint(out, UnifiedDiff(expectedName, "actual output",
+			strings.Split(string(expected), "\n"), strings.Split(string(actual), "\n")))
+		status = 1
+	}
+
+	return status
+}
+
+// replayTranscript runs an input transcript against a fresh Session from the
+// transcript's directory, so that the file names it mentions resolve relative
+// to it, and returns everything the Session printed
+func replayTranscript(input string) ([]byte, error) {
+	file, err := os.Open(input)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	wd, err := os.Getwd()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chdir(filepath.Dir(input)); err != nil {
+		return nil, err
+	}
+
+	defer os.Chdir(wd)
+
+	// stop the clock, so that timestamps come out the same every time
+	defer func(clock func() time.Time) { now = clock }(now)
+	now = func() time.Time { return replayTime }
+
+	var output bytes.Buffer
+	NewCommandDispatcher().Run(NewSession(), file, &output)
+
+	return output.Bytes(), nil
+}
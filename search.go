@@ -0,0 +1,287 @@
+// MIT License
+//
+// Copyright (c) 2019 Gregory Meyer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TitleMatcher decides whether a title matches a search, and where
+type TitleMatcher struct {
+	patterns []*regexp.Regexp
+
+	// literals are strings that matching titles contain, case insensitively:
+	// every one if all is true, or at least one otherwise. nil if unknown.
+	literals []string
+
+	// all is true if every pattern has to match, rather than any of them
+	all bool
+
+	// words is true if the patterns only match where they are not next to a
+	// letter or number
+	words bool
+}
+
+// NewSubstringMatcher matches titles that contain a string, case insensitively
+func NewSubstringMatcher(substr string) *TitleMatcher {
+	return &TitleMatcher{[]*regexp.Regexp{substringPattern(substr)}, []string{substr}, true, false}
+}
+
+// NewWordMatcher matches titles that contain a string as a whole word, case
+// insensitively
+func NewWordMatcher(word string) *TitleMatcher {
+	return &TitleMatcher{[]*regexp.Regexp{substringPattern(word)}, []string{word}, true, true}
+}
+
+// NewRegexpMatcher matches titles that match a regular expression, case
+// insensitively unless the expression says otherwise
+func NewRegexpMatcher(expr string) (*TitleMatcher, error) {
+	pattern, err := regexp.Compile("(?i)" + expr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &TitleMatcher{[]*regexp.Regexp{pattern}, nil, true, false}, nil
+}
+
+// NewTermsMatcher matches titles that contain all of the terms, or any of them
+// if all is false, case insensitively
+func NewTermsMatcher(terms []string, all bool) *TitleMatcher {
+	patterns := make([]*regexp.Regexp, len(terms))
+
+	for i, term := range terms {
+		patterns[i] = substringPattern(term)
+	}
+
+	return &TitleMatcher{patterns, terms, all, false}
+}
+
+func substringPattern(substr string) *regexp.Regexp {
+	return regexp.MustCompile(fmt.Sprintf("(?i)%s", regexp.QuoteMeta(substr)))
+}
+
+// findWords returns the spans of a title that a pattern matches where they are
+// not next to a letter or number, as \b would find them if RE2's \b knew about
+// more than ASCII. A match next to one is tried again a rune later, so matches
+// that would overlap it are still found.
+func findWords(pattern *regexp.Regexp, title string) [][2]int {
+	var spans [][2]int
+
+	for start := 0; start < len(title); {
+		match := pattern.FindStringIndex(title[start:])
+
+		if match == nil {
+			break
+		}
+
+		begin, end := start+match[0], start+match[1]
+
+		if !isWordRune(lastRune(title[:begin])) && !isWordRune(firstRune(title[end:])) {
+			spans = append(spans, [2]int{begin, end})
+			start = maxInt(end, begin+1)
+		} else {
+			_, size := utf8.DecodeRuneInString(title[begin:])
+			start = begin + maxInt(size, 1)
+		}
+	}
+
+	return spans
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+// firstRune returns the first rune of a string, or utf8.RuneError if it is
+// empty
+func firstRune(s string) rune {
+	r, _ := utf8.DecodeRuneInString(s)
+
+	return r
+}
+
+// lastRune returns the last rune of a string, or utf8.RuneError if it is empty
+func lastRune(s string) rune {
+	r, _ := utf8.DecodeLastRuneInString(s)
+
+	return r
+}
+
+// Match returns the spans of a title that match, as pairs of byte offsets
+// sorted and merged so that none overlap, or false if the title doesn't match
+func (m *TitleMatcher) Match(title string) ([][2]int, bool) {
+	var spans [][2]int
+	matched := false
+
+	for _, pattern := range m.patterns {
+		var found [][2]int
+
+		if m.words {
+			found = findWords(pattern, title)
+		} else {
+			for _, match := range pattern.FindAllStringIndex(title, -1) {
+				found = append(found, [2]int{match[0], match[1]})
+			}
+		}
+
+		if len(found) == 0 {
+			if m.all {
+				return nil, false
+			}
+
+			continue
+		}
+
+		matched = true
+		spans = append(spans, found...)
+	}
+
+	if !matched {
+		return nil, false
+	}
+
+	return mergeSpans(spans), true
+}
+
+// candidates uses a TrigramIndex to narrow down the Records that could match.
+// Returns false if the index can't narrow them down.
+func (m *TitleMatcher) candidates(index *TrigramIndex) (map[int]*Record, bool) {
+	if m.literals == nil {
+		return nil, false
+	}
+
+	var candidates map[int]*Record
+	narrowed := false
+
+	for _, literal := range m.literals {
+		found, ok := index.Candidates(literal)
+
+		if !ok {
+			// a literal too short to look up narrows nothing down, which only
+			// matters if a title could match through it alone
+			if m.all {
+				continue
+			}
+
+			return nil, false
+		}
+
+		if !narrowed {
+			candidates, narrowed = found, true
+		} else if m.all {
+			for id := range candidates {
+				if _, ok := found[id]; !ok {
+					delete(candidates, id)
+				}
+			}
+		} else {
+			for id, record := range found {
+				candidates[id] = record
+			}
+		}
+	}
+
+	return candidates, narrowed
+}
+
+func mergeSpans(spans [][2]int) [][2]int {
+	sort.Slice(spans, func(i, j int) bool {
+		return spans[i][0] < spans[j][0]
+	})
+
+	var merged [][2]int
+
+	for _, span := range spans {
+		last := len(merged) - 1
+
+		if last >= 0 && span[0] <= merged[last][1] {
+			merged[last][1] = maxInt(merged[last][1], span[1])
+		} else if span[1] > span[0] {
+			merged = append(merged, span)
+		}
+	}
+
+	return merged
+}
+
+// SearchResult is a Record that matched a search and the spans of its title
+// that matched
+type SearchResult struct {
+	record *Record
+	spans  [][2]int
+}
+
+const (
+	highlightStart = "\x1b[1;4m"
+	highlightEnd   = "\x1b[0m"
+)
+
+// SprintSearchResults prints SearchResults to a string like SprintRecords,
+// underlining the matched spans of each title in bold if highlight is true.
+// If table is not nil, the results are printed as its rows and highlighted.
+func SprintSearchResults(results []SearchResult, highlight bool, table *Table) string {
+	records := make([]*Record, len(results))
+	spans := make([][][2]int, len(results))
+
+	for i, result := range results {
+		records[i] = result.record
+		spans[i] = result.spans
+	}
+
+	if table != nil {
+		return strings.Join(table.Rows(records, spans), "\n")
+	} else if !highlight {
+		return SprintRecords(records)
+	}
+
+	lines := make([]string, len(results))
+
+	for i, result := range results {
+		lines[i] = result.record.stringWithTitle(highlightSpans(result.record.title, result.spans))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func highlightSpans(title string, spans [][2]int) string {
+	var builder strings.Builder
+	last := 0
+
+	for _, span := range spans {
+		builder.WriteString(title[last:span[0]])
+		builder.WriteString(highlightStart)
+		builder.WriteString(title[span[0]:span[1]])
+		builder.WriteString(highlightEnd)
+		last = span[1]
+	}
+
+	builder.WriteString(title[last:])
+
+	return builder.String()
+}
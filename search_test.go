@@ -0,0 +1,142 @@
+// MIT License
+//
+// Copyright (c) 2019 Gregory Meyer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWordMatcher(t *testing.T) {
+	for _, test := range []struct {
+		word, title string
+		want        [][2]int
+	}{
+		{"a", "a a", [][2]int{{0, 1}, {2, 3}}},
+		{"a", "a a a", [][2]int{{0, 1}, {2, 3}, {4, 5}}},
+		{"the", "The Return of the King", [][2]int{{0, 3}, {14, 17}}},
+		{"a", "aa a", [][2]int{{3, 4}}},
+		{"ete", "Été ete", [][2]int{{6, 9}}},
+		{"été", "l'été", [][2]int{{2, 7}}},
+		{"war", "Warcraft", nil},
+		{"-", "a-b - c", [][2]int{{4, 5}}},
+	} {
+		spans, ok := NewWordMatcher(test.word).Match(test.title)
+
+		if ok != (test.want != nil) || !reflect.DeepEqual(spans, test.want) {
+			t.Errorf("-w %q in %q matched %v, want %v", test.word, test.title, spans, test.want)
+		}
+	}
+}
+
+func TestRegexpMatcherReportsWholeMatch(t *testing.T) {
+	matcher, err := NewRegexpMatcher("(al)ien")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if spans, _ := matcher.Match("Alien"); !reflect.DeepEqual(spans, [][2]int{{0, 5}}) {
+		t.Errorf("-r (al)ien in Alien matched %v, want the whole title", spans)
+	}
+}
+
+func TestSearchModes(t *testing.T) {
+	library := NewLibrary()
+
+	for _, title := range []string{
+		"Alien", "Kingdom of Heaven", "Return to Oz", "The King and I", "The Return of the King",
+	} {
+		if _, err := library.AddRecord("DVD", title); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mustRegexp := func(expr string) *TitleMatcher {
+		matcher, err := NewRegexpMatcher(expr)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return matcher
+	}
+
+	for _, test := range []struct {
+		search  string
+		matcher *TitleMatcher
+		want    []string
+	}{
+		{"-p return of", NewSubstringMatcher("return of"), []string{"The Return of the King"}},
+		{"-p king and", NewSubstringMatcher("king and"), []string{"The King and I"}},
+		{"-p of the return", NewSubstringMatcher("of the return"), nil},
+		{"-r king$", mustRegexp("king$"), []string{"The Return of the King"}},
+		{"-r ^the .* i$", mustRegexp("^the .* i$"), []string{"The King and I"}},
+		{"-r (?-i)^alien", mustRegexp("(?-i)^alien"), nil},
+		{"-a king return", NewTermsMatcher([]string{"king", "return"}, true), []string{"The Return of the King"}},
+		{"-a king oz", NewTermsMatcher([]string{"king", "oz"}, true), nil},
+		{"-o oz alien", NewTermsMatcher([]string{"oz", "alien"}, false), []string{"Alien", "Return to Oz"}},
+		{"-o king", NewTermsMatcher([]string{"king"}, false),
+			[]string{"Kingdom of Heaven", "The King and I", "The Return of the King"}},
+		{"-o zardoz ox", NewTermsMatcher([]string{"zardoz", "ox"}, false), nil},
+	} {
+		var titles []string
+
+		for _, result := range library.Search(test.matcher) {
+			titles = append(titles, result.record.title)
+		}
+
+		if !reflect.DeepEqual(titles, test.want) {
+			t.Errorf("fs %s found %q, want %q", test.search, titles, test.want)
+		}
+	}
+}
+
+func TestSearchErrors(t *testing.T) {
+	session, dispatcher := NewSession(), NewCommandDispatcher()
+
+	if _, err := session.library.AddRecord("DVD", "Alien"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		line, want string
+	}{
+		{"fs -r (alien", "Could not read a regular expression!"},
+		{"fs -r", "Could not read a regular expression!"},
+		{"fs -a", "Could not read any search terms!"},
+		{"fs -o   ", "Could not read any search terms!"},
+		{"fs -p aliens", errNoMatchingRecords},
+		{"fs -a alien zardoz", errNoMatchingRecords},
+	} {
+		args := NewArgs(strings.NewReader(test.line + "\n"))
+		name, _ := args.Command()
+		err := dispatcher.Execute(session, name, args, ioutil.Discard)
+
+		if err == nil || err.Error() != test.want {
+			t.Errorf("%s returned %v, want %s", test.line, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+// MIT License
+//
+// Copyright (c) 2019 Gregory Meyer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Session is the state that Commands operate on
+type Session struct {
+	library  *Library
+	catalog  *Catalog
+	journal  *Journal
+	storage  Storage
+	autosave *Autosave
+
+	// dirty is true if the Library or Catalog changed since they were last
+	// saved or restored, and the changes aren't safe in a Journal or Storage
+	dirty bool
+
+	// interactive is true if a person is typing commands, so it is worth
+	// asking them before discarding unsaved changes
+	interactive bool
+
+	// terminal is true if output goes to a terminal, so it can be highlighted
+	terminal bool
+
+	// table prints Records as aligned columns, or is nil to print them plainly
+	table *Table
+
+	// pageSize is the number of items listed at once, or 0 to list everything
+	pageSize int
+
+	// listing is the last Listing printed, for moving between its pages
+	listing *Listing
+
+	// pager is a shell command that shows listings too tall for the terminal
+	pager string
+
+	// suggest is true if commands that find no Record with a title should
+	// suggest the most similar titles instead
+	suggest bool
+
+	// ratingScale is the RatingScale that restored Libraries are moved to, or
+	// nil to keep the RatingScale they were saved with
+	ratingScale *RatingScale
+
+	// user is the user whose ratings mr gives, or unattributedUser
+	user string
+
+	// changes are what mutating commands changed since the Storage last
+	// stored the Session
+	changes Changes
+
+	// mutex is held while a Command runs, so an Autosave can run between them
+	mutex sync.Mutex
+}
+
+// NewSession creates a Session with an empty Library and Catalog
+func NewSession() *Session {
+	return &Session{library: NewLibrary(), catalog: NewCatalog()}
+}
+
+// saveFormatVersion is the version of the format Save writes. Version 1 files,
+// which have no version line, name the members of Collections by title.
+const saveFormatVersion = 2
+
+// Save serializes the Library and Catalog of a Session to an io.Writer
+func (s *Session) Save(writer io.Writer) {
+	FprintfOrPanic(writer, "version %d\n", saveFormatVersion)
+	s.library.Save(writer)
+	s.catalog.Save(writer)
+}
+
+// SaveFile saves the Library and Catalog of a Session to a file, replacing it
+// atomically. Saving to the data file of the Session's Journal compacts it.
+func (s *Session) SaveFile(filename string) error {
+	var err error
+
+	if s.journal != nil && s.journal.Covers(filename) {
+		err = s.journal.Compact(s)
+	} else {
+		var snapshot bytes.Buffer
+		s.Save(&snapshot)
+		err = writeFileAtomically(filename, snapshot.Bytes())
+	}
+
+	if err != nil {
+		return err
+	}
+
+	s.markClean()
+
+	return nil
+}
+
+// Restore replaces the Library and Catalog of a Session with ones deserialized
+// from a *bufio.Reader. The Session is unchanged if the data is invalid.
+func (s *Session) Restore(reader *bufio.Reader) Error {
+	version, err := readSaveFormatVersion(reader)
+
+	if err != nil {
+		return err
+	}
+
+	library, err := RestoreLibrary(reader, s.library.articles)
+
+	if err != nil {
+		return err
+	}
+
+	catalog, err := RestoreCatalog(reader, library, version)
+
+	if err != nil {
+		return err
+	}
+
+	if s.ratingScale != nil {
+		library.Rescale(*s.ratingScale, catalog)
+	}
+
+	library.duplicateTitles = s.library.duplicateTitles
+	library.foldedTitles = s.library.foldedTitles
+
+	*s.library = *library
+	*s.catalog = *catalog
+	s.listing = nil
+	s.everythingChanged()
+	s.markClean()
+
+	return nil
+}
+
+// Changes are the Records, by ID, and the Collections, by name, that were
+// added, changed or deleted, so that Storage only writes those
+type Changes struct {
+	records     map[int]bool
+	collections map[string]bool
+
+	// all is true if anything could have changed
+	all bool
+}
+
+// recordChanged notes that a Record was added, changed or deleted
+func (s *Session) recordChanged(id int) {
+	if s.changes.records == nil {
+		s.changes.records = make(map[int]bool)
+	}
+
+	s.changes.records[id] = true
+}
+
+// collectionChanged notes that a Collection was added, changed or deleted
+func (s *Session) collectionChanged(name string) {
+	if s.changes.collections == nil {
+		s.changes.collections = make(map[string]bool)
+	}
+
+	s.changes.collections[name] = true
+}
+
+// everythingChanged notes that any Record or Collection could have changed
+func (s *Session) everythingChanged() {
+	s.changes = Changes{all: true}
+}
+
+// store writes the changes to the Session to its Storage, forgetting them
+// once they are stored
+func (s *Session) store() error {
+	if err := s.storage.Store(s); err != nil {
+		return err
+	}
+
+	s.changes = Changes{}
+
+	return nil
+}
+
+// readSaveFormatVersion reads the version line of a file written by Save, or
+// returns 1 if the file was saved before it had one. Versions newer than
+// saveFormatVersion can't be read.
+func readSaveFormatVersion(reader *bufio.Reader) (int, Error) {
+	SkipWhitespace(reader)
+
+	if next, err := reader.Peek(1); err != nil || next[0] != 'v' {
+		return 1, nil
+	}
+
+	fields := strings.Fields(ReadLine(reader))
+
+	if len(fields) != 2 || fields[0] != "version" {
+		return 0, NewlineError(ErrInvalidFile)
+	}
+
+	version, err := strconv.Atoi(fields[1])
+
+	if err != nil || version < 1 {
+		return 0, NewlineError(ErrInvalidFile)
+	} else if version > saveFormatVersion {
+		return 0, NewlineError(fmt.Sprintf("File was saved by a newer version (format %d)!", version))
+	}
+
+	return version, nil
+}
+
+// sprintRecords prints Records like SprintRecords, or as rows of the Session's
+// Table if it has one
+func (s *Session) sprintRecords(records []*Record) string {
+	if s.table != nil {
+		return s.table.SprintRecords(records)
+	}
+
+	return SprintRecords(records)
+}
+
+func (s *Session) markClean() {
+	s.dirty = false
+
+	if s.autosave != nil {
+		s.autosave.numChanges = 0
+	}
+}
@@ -0,0 +1,394 @@
+// MIT License
+//
+// Copyright (c) 2019 Gregory Meyer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Statistics summarizes a Library and Catalog
+type Statistics struct {
+	NumRecords     int `json:"record_count"`
+	NumCollections int `json:"collection_count"`
+
+	// NumUncollected is the number of Records in no Collection
+	NumUncollected int `json:"uncollected_count"`
+
+	ByMedium []MediumStatistics `json:"mediums"`
+
+	// Scale is the RatingScale of the ratings, as ParseRatingScale reads it
+	Scale string `json:"rating_scale"`
+
+	// RatingCounts counts the Records with each rating on the scale, highest
+	// first, counting a Record rated by several users under the rating nearest
+	// the mean of theirs. Scales with too many ratings to list only count the
+	// ratings some Record has.
+	RatingCounts []RatingCount `json:"rating_counts"`
+	NumUnrated   int           `json:"unrated_count"`
+
+	// ByUser summarizes the ratings of each user, in order of name. Ratings
+	// given while no user was set are left out.
+	ByUser []UserStatistics `json:"users"`
+
+	// Disputed names the Records whose raters disagree the most, most disputed
+	// first
+	Disputed []DisputedRecord `json:"disputed_records"`
+
+	Collections []CollectionStatistics `json:"collections"`
+
+	// Largest and Smallest name the Collections with the most and the fewest
+	// members, in order of name
+	Largest  []string `json:"largest_collections"`
+	Smallest []string `json:"smallest_collections"`
+}
+
+// RatingCount is the number of Records with a rating
+type RatingCount struct {
+	Rating float64 `json:"rating"`
+	Count  int     `json:"count"`
+}
+
+// UserStatistics summarizes the ratings one user gave
+type UserStatistics struct {
+	User     string  `json:"user"`
+	NumRated int     `json:"rated_records"`
+	Average  float64 `json:"average_rating"`
+}
+
+// DisputedRecord is a Record rated by more than one user who disagree
+type DisputedRecord struct {
+	ID           int     `json:"id"`
+	Title        string  `json:"title"`
+	NumRatings   int     `json:"rating_count"`
+	Mean         float64 `json:"mean"`
+	Median       float64 `json:"median"`
+	Disagreement float64 `json:"disagreement"`
+}
+
+// maxDisputed is the most Records listed as disputed
+const maxDisputed = 5
+
+// maxListedRatings is the most ratings a scale can have for each one to be
+// counted, even if no Record has it
+const maxListedRatings = 21
+
+// MediumStatistics summarizes the Records of one medium
+type MediumStatistics struct {
+	Medium     string `json:"medium"`
+	NumRecords int    `json:"record_count"`
+	NumRated   int    `json:"rated_records"`
+
+	// AverageRating is the mean of the ratings of the rated Records, or nil
+	// if none are rated
+	AverageRating *float64 `json:"average_rating"`
+}
+
+// CollectionStatistics summarizes the members of one Collection
+type CollectionStatistics struct {
+	Name       string `json:"name"`
+	NumMembers int    `json:"members"`
+	NumRated   int    `json:"rated_members"`
+
+	// AverageRating is the mean of the ratings of the rated members, or nil if
+	// none are rated
+	AverageRating *float64 `json:"average_rating"`
+}
+
+// ComputeStatistics summarizes a Library and the Collections of a Catalog
+func ComputeStatistics(library *Library, catalog *Catalog) *Statistics {
+	// lists are empty rather than nil so that JSON has [] rather than null
+	stats := &Statistics{
+		NumRecords:     library.NumRecords(),
+		NumCollections: catalog.NumCollections(),
+		ByMedium:       []MediumStatistics{},
+		Scale:          library.Scale().String(),
+		RatingCounts:   []RatingCount{},
+		ByUser:         []UserStatistics{},
+		Disputed:       []DisputedRecord{},
+		Collections:    []CollectionStatistics{},
+	}
+
+	mediums := make(map[string]*MediumStatistics)
+	ratingTotals := make(map[string]Rating)
+	ratingCounts := make(map[Rating]int)
+	users := make(map[string]*UserStatistics)
+	userTotals := make(map[string]Rating)
+
+	for _, record := range library.sortedRecords() {
+		medium, ok := mediums[record.medium]
+
+		if !ok {
+			medium = &MediumStatistics{Medium: record.medium}
+			mediums[record.medium] = medium
+		}
+
+		medium.NumRecords++
+
+		if record.rating == NoRating {
+			stats.NumUnrated++
+		} else {
+			ratingCounts[library.Scale().Nearest(record.rating)]++
+			medium.NumRated++
+			ratingTotals[record.medium] += record.rating
+		}
+
+		if record.numCollections == 0 {
+			stats.NumUncollected++
+		}
+
+		for name, rating := range record.ratings {
+			if name == unattributedUser {
+				continue
+			}
+
+			user, ok := users[name]
+
+			if !ok {
+				user = &UserStatistics{User: name}
+				users[name] = user
+			}
+
+			user.NumRated++
+			userTotals[name] += rating
+		}
+
+		if summary := record.Summary(); summary.Count > 1 && summary.Disagreement > 0 {
+			stats.Disputed = append(stats.Disputed, DisputedRecord{record.id, record.title, summary.Count,
+				summary.Mean.Float(), summary.Median.Float(), summary.Disagreement.Float()})
+		}
+	}
+
+	for name, user := range users {
+		user.Average = *average(userTotals[name], user.NumRated)
+		stats.ByUser = append(stats.ByUser, *user)
+	}
+
+	sort.Slice(stats.ByUser, func(i, j int) bool {
+		return stats.ByUser[i].User < stats.ByUser[j].User
+	})
+
+	// the Records are in order of title, which breaks ties
+	sort.SliceStable(stats.Disputed, func(i, j int) bool {
+		return stats.Disputed[i].Disagreement > stats.Disputed[j].Disagreement
+	})
+
+	if len(stats.Disputed) > maxDisputed {
+		stats.Disputed = stats.Disputed[:maxDisputed]
+	}
+
+	values := library.Scale().Values()
+
+	for _, rating := range values {
+		if count := ratingCounts[rating]; count > 0 || len(values) <= maxListedRatings {
+			stats.RatingCounts = append(stats.RatingCounts, RatingCount{rating.Float(), count})
+		}
+	}
+
+	for name, medium := range mediums {
+		medium.AverageRating = average(ratingTotals[name], medium.NumRated)
+		stats.ByMedium = append(stats.ByMedium, *medium)
+	}
+
+	sort.Slice(stats.ByMedium, func(i, j int) bool {
+		return stats.ByMedium[i].Medium < stats.ByMedium[j].Medium
+	})
+
+	for _, collection := range catalog.sortedCollections() {
+		collectionStats := CollectionStatistics{Name: collection.name, NumMembers: len(collection.members)}
+		total := Rating(0)
+
+		for _, record := range collection.members {
+			if record.rating != NoRating {
+				collectionStats.NumRated++
+				total += record.rating
+			}
+		}
+
+		collectionStats.AverageRating = average(total, collectionStats.NumRated)
+		stats.Collections = append(stats.Collections, collectionStats)
+	}
+
+	stats.Largest, stats.Smallest = extremeCollections(stats.Collections)
+
+	return stats
+}
+
+// extremeCollections names the Collections with the most and the fewest
+// members
+func extremeCollections(collections []CollectionStatistics) (largest, smallest []string) {
+	largest, smallest = []string{}, []string{}
+
+	if len(collections) == 0 {
+		return largest, smallest
+	}
+
+	most, fewest := collections[0].NumMembers, collections[0].NumMembers
+
+	for _, collection := range collections {
+		most = maxInt(most, collection.NumMembers)
+		fewest = minInt(fewest, collection.NumMembers)
+	}
+
+	for _, collection := range collections {
+		if collection.NumMembers == most {
+			largest = append(largest, collection.Name)
+		}
+
+		if collection.NumMembers == fewest {
+			smallest = append(smallest, collection.Name)
+		}
+	}
+
+	return largest, smallest
+}
+
+func average(total Rating, count int) *float64 {
+	if count == 0 {
+		return nil
+	}
+
+	mean := total.Float() / float64(count)
+
+	return &mean
+}
+
+// JSON returns these Statistics as indented JSON
+func (s *Statistics) JSON() string {
+	encoded, err := json.MarshalIndent(s, "", "  ")
+
+	if err != nil {
+		panic(err)
+	}
+
+	return string(encoded)
+}
+
+func (s *Statistics) String() string {
+	var builder strings.Builder
+
+	builder.WriteString(fmt.Sprintf("%d Records, %d Collections\n", s.NumRecords, s.NumCollections))
+	builder.WriteString(fmt.Sprintf("%d Records are in no Collection\n", s.NumUncollected))
+
+	builder.WriteString("By medium:")
+
+	if len(s.ByMedium) == 0 {
+		builder.WriteString(" None")
+	}
+
+	for _, medium := range s.ByMedium {
+		builder.WriteString(fmt.Sprintf("\n  %s: %d Records, %d rated, average rating %s",
+			medium.Medium, medium.NumRecords, medium.NumRated, formatAverage(medium.AverageRating)))
+	}
+
+	builder.WriteString(fmt.Sprintf("\nBy rating (%s):", s.Scale))
+
+	for _, ratingCount := range s.RatingCounts {
+		label := Rating(math.Round(ratingCount.Rating * ratingUnit)).String()
+		builder.WriteString(s.histogramRow(label, ratingCount.Count))
+	}
+
+	builder.WriteString(s.histogramRow(NoRating.String(), s.NumUnrated))
+
+	if len(s.ByUser) > 0 {
+		builder.WriteString("\nBy user:")
+	}
+
+	for _, user := range s.ByUser {
+		builder.WriteString(fmt.Sprintf("\n  %s: %d rated, average rating %s",
+			user.User, user.NumRated, formatAverage(&user.Average)))
+	}
+
+	if len(s.Disputed) > 0 {
+		builder.WriteString("\nMost disputed:")
+	}
+
+	for _, record := range s.Disputed {
+		builder.WriteString(fmt.Sprintf("\n  %d: %s (%d ratings, mean %.2f, median %.2f, disagreement %.2f)",
+			record.ID, record.Title, record.NumRatings, record.Mean, record.Median, record.Disagreement))
+	}
+
+	builder.WriteString("\nBy collection:")
+
+	if len(s.Collections) == 0 {
+		builder.WriteString(" None")
+	}
+
+	for _, collection := range s.Collections {
+		builder.WriteString(fmt.Sprintf("\n  %s: %d members, %d rated, average rating %s",
+			collection.Name, collection.NumMembers, collection.NumRated, formatAverage(collection.AverageRating)))
+	}
+
+	if len(s.Collections) > 0 {
+		builder.WriteString(fmt.Sprintf("\nLargest: %s (%d members)",
+			strings.Join(s.Largest, ", "), s.collectionSize(s.Largest[0])))
+		builder.WriteString(fmt.Sprintf("\nSmallest: %s (%d members)",
+			strings.Join(s.Smallest, ", "), s.collectionSize(s.Smallest[0])))
+	}
+
+	return builder.String()
+}
+
+// histogramRow prints a row of the histogram of ratings
+func (s *Statistics) histogramRow(label string, count int) string {
+	bar := strings.Repeat("#", histogramWidth(count, s.NumRecords))
+
+	if bar != "" {
+		bar += " "
+	}
+
+	return fmt.Sprintf("\n  %s: %s%d", label, bar, count)
+}
+
+func (s *Statistics) collectionSize(name string) int {
+	for _, collection := range s.Collections {
+		if collection.Name == name {
+			return collection.NumMembers
+		}
+	}
+
+	return 0
+}
+
+const maxHistogramWidth = 40
+
+// histogramWidth scales a count to the length of a bar of at most
+// maxHistogramWidth, at least 1 for any count above 0
+func histogramWidth(count, total int) int {
+	if count == 0 || total == 0 {
+		return 0
+	}
+
+	return maxInt(1, count*maxHistogramWidth/total)
+}
+
+func formatAverage(mean *float64) string {
+	if mean == nil {
+		return "none"
+	}
+
+	return fmt.Sprintf("%.2f", *mean)
+}
@@ -0,0 +1,41 @@
+// MIT License
+//
+// Copyright (c) 2019 Gregory Meyer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStatisticsJSONListsAreNeverNull(t *testing.T) {
+	// a scale with too many ratings to list them all counts none of them
+	library := NewLibrary()
+	library.Rescale(RatingScale{0, 100 * ratingUnit, ratingUnit / 2}, nil)
+	json := ComputeStatistics(library, NewCatalog()).JSON()
+
+	for _, list := range []string{"mediums", "rating_counts", "users", "disputed_records", "collections", "largest_collections", "smallest_collections"} {
+		if !strings.Contains(json, `"`+list+`": []`) {
+			t.Errorf("%s is not an empty list in %s", list, json)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+// MIT License
+//
+// Copyright (c) 2019 Gregory Meyer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Storage persists the Library and Catalog of a Session between runs
+type Storage interface {
+	// Load replaces the Library and Catalog of a Session with the stored ones
+	Load(session *Session) error
+
+	// Store persists the Library and Catalog of a Session, which may write
+	// only what changed since they were last loaded or stored
+	Store(session *Session) error
+
+	// Close releases any resources held by the Storage
+	Close() error
+}
+
+const errUnwritableStorage = "Could not write to storage!"
+
+// OpenStorage opens a Storage of the named format: "text" for the format
+// written by sA, or "db" for a Database that is written incrementally
+func OpenStorage(format, filename string) (Storage, error) {
+	switch format {
+	case "text":
+		return &TextStorage{filename}, nil
+	case "db":
+		db, err := OpenDatabase(filename)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &DatabaseStorage{db}, nil
+	default:
+		return nil, fmt.Errorf("unknown storage format %q", format)
+	}
+}
+
+// TextStorage stores a Session in the format written by sA, rewriting the
+// whole file every time
+type TextStorage struct {
+	filename string
+}
+
+// Load restores a Session from the file, if it exists
+func (t *TextStorage) Load(session *Session) error {
+	contents, err := ioutil.ReadFile(t.filename)
+
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if err := session.Restore(bufio.NewReader(bytes.NewReader(contents))); err != nil {
+		return fmt.Errorf("%s: %v", t.filename, err)
+	}
+
+	return nil
+}
+
+// Store saves a Session to the file
+func (t *TextStorage) Store(session *Session) error {
+	var contents bytes.Buffer
+	session.Save(&contents)
+
+	return writeFileAtomically(t.filename, contents.Bytes())
+}
+
+// Close does nothing
+func (t *TextStorage) Close() error {
+	return nil
+}
+
+// DatabaseStorage stores each Record and Collection of a Session under its own
+// key in a Database, so only the ones that changed are written
+type DatabaseStorage struct {
+	db *Database
+}
+
+const (
+	versionKey          = "version"
+	scaleKey            = "scale"
+	nextIDKey           = "next"
+	recordKeyPrefix     = "record/"
+	collectionKeyPrefix = "collection/"
+)
+
+// Load restores a Session from the Database by assembling the entries into
+// the format written by sA
+func (d *DatabaseStorage) Load(session *Session) error {
+	var records, collections []string
+
+	for _, key := range d.db.Keys() {
+		value, _ := d.db.Get(key)
+
+		if strings.HasPrefix(key, recordKeyPrefix) {
+			records = append(records, string(value))
+		} else if strings.HasPrefix(key, collectionKeyPrefix) {
+			collections = append(collections, string(value))
+		}
+	}
+
+	var contents strings.Builder
+
+	// Databases written before Collections named their members by ID have no
+	// version, and those written before ratings had scales have no scale, so
+	// they are read the old way
+	if version, ok := d.db.Get(versionKey); ok {
+		contents.Write(version)
+	}
+
+	if scale, ok := d.db.Get(scaleKey); ok {
+		contents.Write(scale)
+	}
+
+	if nextID, ok := d.db.Get(nextIDKey); ok {
+		contents.Write(nextID)
+	}
+
+	contents.WriteString(fmt.Sprintf("%d\n", len(records)))
+	contents.WriteString(strings.Join(records, ""))
+	contents.WriteString(fmt.Sprintf("%d\n", len(collections)))
+	contents.WriteString(strings.Join(collections, ""))
+
+	if err := session.Restore(bufio.NewReader(strings.NewReader(contents.String()))); err != nil {
+		return fmt.Errorf("%s: %v", d.db.filename, err)
+	}
+
+	return nil
+}
+
+// Store writes the Records and Collections that commands changed, or all of
+// them if anything could have changed, in one Batch. Those that no longer exist
+// are deleted.
+func (d *DatabaseStorage) Store(session *Session) error {
+	current := make(map[string][]byte)
+	current[versionKey] = []byte(fmt.Sprintf("version %d\n", saveFormatVersion))
+	current[scaleKey] = []byte(fmt.Sprintf("scale %s\n", session.library.Scale()))
+	current[nextIDKey] = []byte(fmt.Sprintf("next %d\n", session.library.nextID))
+	var deleted []string
+
+	if session.changes.all {
+		for id, record := range session.library.byID {
+			current[recordKeyPrefix+strconv.Itoa(id)] = savedRecord(record)
+		}
+
+		for name, collection := range session.catalog.collections {
+			current[collectionKeyPrefix+name] = savedCollection(collection)
+		}
+
+		for _, key := range d.db.Keys() {
+			if _, ok := current[key]; !ok {
+				deleted = append(deleted, key)
+			}
+		}
+	} else {
+		for id := range session.changes.records {
+			key := recordKeyPrefix + strconv.Itoa(id)
+
+			if record, ok := session.library.byID[id]; ok {
+				current[key] = savedRecord(record)
+			} else {
+				deleted = append(deleted, key)
+			}
+		}
+
+		for name := range session.changes.collections {
+			key := collectionKeyPrefix + name
+
+			if collection, ok := session.catalog.collections[name]; ok {
+				current[key] = savedCollection(collection)
+			} else {
+				deleted = append(deleted, key)
+			}
+		}
+	}
+
+	batch := NewBatch()
+	sort.Strings(deleted)
+
+	for _, key := range deleted {
+		if _, ok := d.db.Get(key); ok {
+			batch.Delete(key)
+		}
+	}
+
+	keys := make([]string, 0, len(current))
+
+	for key := range current {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if stored, ok := d.db.Get(key); !ok || !bytes.Equal(stored, current[key]) {
+			batch.Put(key, current[key])
+		}
+	}
+
+	return d.db.Write(batch)
+}
+
+// savedRecord returns a Record as Save writes it
+func savedRecord(record *Record) []byte {
+	var value bytes.Buffer
+	record.Save(&value)
+
+	return value.Bytes()
+}
+
+// savedCollection returns a Collection as Save writes it
+func savedCollection(collection *Collection) []byte {
+	var value bytes.Buffer
+	collection.Save(&value)
+
+	return value.Bytes()
+}
+
+// Close closes the Database
+func (d *DatabaseStorage) Close() error {
+	return d.db.Close()
+}
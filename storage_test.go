@@ -0,0 +1,136 @@
+// MIT License
+//
+// Copyright (c) 2019 Gregory Meyer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// openStored opens a Session stored in a Database, as -store does
+func openStored(t *testing.T, filename string) (*Session, *Dispatcher, Storage) {
+	t.Helper()
+
+	storage, err := OpenStorage("db", filename)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session := NewSession()
+
+	if err := storage.Load(session); err != nil {
+		t.Fatal(err)
+	}
+
+	session.storage = storage
+	session.changes = Changes{}
+
+	return session, NewCommandDispatcher(), storage
+}
+
+// saved returns a Session as sA writes it
+func saved(session *Session) string {
+	var contents bytes.Buffer
+	session.Save(&contents)
+
+	return contents.String()
+}
+
+func TestDatabaseStorageRoundTrip(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "library.db")
+
+	session, dispatcher, storage := openStored(t, filename)
+
+	for _, line := range []string{
+		"ar DVD Alien",
+		"ar VHS Brazil",
+		"ar Blu-ray Casablanca",
+		"mr 1 4",
+		"ac classics",
+		"am classics 3",
+		"am classics 1",
+		"dr Brazil",
+		"ac scratch",
+		"mc scratch drafts",
+		"mt 3 Casablanca (1942)",
+	} {
+		runCommand(t, session, dispatcher, line)
+	}
+
+	want := saved(session)
+
+	if err := storage.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, _, storage := openStored(t, filename)
+	defer storage.Close()
+
+	if got := saved(restored); got != want {
+		t.Errorf("restored:\n%s\nwant:\n%s", got, want)
+	}
+
+	db := storage.(*DatabaseStorage).db
+
+	for _, key := range []string{recordKeyPrefix + "2", collectionKeyPrefix + "scratch"} {
+		if _, ok := db.Get(key); ok {
+			t.Errorf("%s was not deleted", key)
+		}
+	}
+}
+
+func TestDatabaseStorageStoresOnlyChanges(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "library.db")
+
+	session, dispatcher, storage := openStored(t, filename)
+	defer storage.Close()
+
+	runCommand(t, session, dispatcher, "ar DVD Alien")
+	runCommand(t, session, dispatcher, "ar DVD Brazil")
+
+	// a Record changed behind the commands' back isn't stored until a command
+	// changes it
+	session.library.byID[1].title = "Aliens"
+	runCommand(t, session, dispatcher, "mr 2 5")
+
+	db := storage.(*DatabaseStorage).db
+
+	if value, _ := db.Get(recordKeyPrefix + "1"); !bytes.Contains(value, []byte(" Alien\n")) {
+		t.Errorf("record 1 was stored as %q", value)
+	}
+
+	if value, _ := db.Get(recordKeyPrefix + "2"); !bytes.HasPrefix(value, []byte("2 DVD 5 Brazil\n")) {
+		t.Errorf("record 2 was stored as %q", value)
+	}
+
+	if session.changes.all || len(session.changes.records) > 0 {
+		t.Errorf("changes were kept after storing: %+v", session.changes)
+	}
+}
@@ -0,0 +1,170 @@
+// MIT License
+//
+// Copyright (c) 2019 Gregory Meyer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"hash/crc32"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// Table prints Records for a terminal as aligned columns, with ratings as
+// stars, mediums in color, and titles cut short to fit the terminal's width
+type Table struct {
+	file  *os.File
+	color bool
+
+	// library has the RatingScale that ratings are drawn as stars out of
+	library *Library
+}
+
+// NewTable creates a Table for a terminal, for Records of a Library. Color can
+// be turned off.
+func NewTable(file *os.File, color bool, library *Library) *Table {
+	return &Table{file, color, library}
+}
+
+const (
+	colorReset      = "\x1b[0m"
+	fmtColorStart   = "\x1b[%dm"
+	starFull        = "★"
+	starHalf        = "½"
+	starEmpty       = "☆"
+	numStars        = 5
+	unratedMarker   = "·"
+	minTitleWidth   = 10
+	truncatedMarker = "…"
+)
+
+// mediumColors are the ANSI foreground colors given to mediums
+var mediumColors = []int{31, 32, 33, 34, 35, 36}
+
+// Rows prints one row per Record. If spans is not nil, it holds the spans of
+// each title to highlight, as returned by TitleMatcher.Match.
+func (t *Table) Rows(records []*Record, spans [][][2]int) []string {
+	idWidth, mediumWidth := 0, 0
+
+	for _, record := range records {
+		idWidth = maxInt(idWidth, len(fmt.Sprint(record.id)))
+		mediumWidth = maxInt(mediumWidth, utf8.RuneCountInString(record.medium))
+	}
+
+	titleWidth := 0
+
+	if _, width := TerminalSize(t.file); width > 0 {
+		// the columns are separated by two spaces each
+		titleWidth = maxInt(minTitleWidth, width-idWidth-mediumWidth-numStars-6)
+	}
+
+	rows := make([]string, len(records))
+
+	for i, record := range records {
+		status := record.loanStatus()
+		width := titleWidth
+
+		if status != "" && titleWidth > 0 {
+			width = maxInt(minTitleWidth, titleWidth-utf8.RuneCountInString(status)-1)
+		}
+
+		title, truncated := truncateTitle(record.title, width)
+
+		if spans != nil && truncated {
+			kept := len(title) - len(truncatedMarker)
+			title = highlightSpans(title[:kept], clipSpans(spans[i], kept)) + truncatedMarker
+		} else if spans != nil {
+			title = highlightSpans(title, spans[i])
+		}
+
+		if status != "" {
+			title += " " + status
+		}
+
+		rows[i] = fmt.Sprintf("%*d  %s  %s  %s", idWidth, record.id,
+			t.colorMedium(record.medium, mediumWidth), t.stars(record.rating), title)
+	}
+
+	return rows
+}
+
+// SprintRecords prints Records like the package-level SprintRecords, as rows
+// of this Table
+func (t *Table) SprintRecords(records []*Record) string {
+	return strings.Join(t.Rows(records, nil), "\n")
+}
+
+func (t *Table) colorMedium(medium string, width int) string {
+	padded := medium + strings.Repeat(" ", width-utf8.RuneCountInString(medium))
+
+	if !t.color {
+		return padded
+	}
+
+	color := mediumColors[crc32.ChecksumIEEE([]byte(medium))%uint32(len(mediumColors))]
+
+	return fmt.Sprintf(fmtColorStart, color) + padded + colorReset
+}
+
+// stars prints a rating as five stars, filled to the nearest half star in
+// proportion to the maximum of the RatingScale, or as a row of dots if the
+// Record is unrated
+func (t *Table) stars(rating Rating) string {
+	if rating == NoRating {
+		return strings.Repeat(unratedMarker, numStars)
+	}
+
+	halves := t.library.Scale().halfStars(rating)
+	stars := strings.Repeat(starFull, halves/2)
+
+	if halves%2 == 1 {
+		stars += starHalf
+	}
+
+	return stars + strings.Repeat(starEmpty, numStars-(halves+1)/2)
+}
+
+// truncateTitle cuts a title short to at most width runes, ending it with an
+// ellipsis, unless width is 0. Returns true if the title was cut.
+func truncateTitle(title string, width int) (string, bool) {
+	if width <= 0 || utf8.RuneCountInString(title) <= width {
+		return title, false
+	}
+
+	runes := []rune(title)
+
+	return string(runes[:width-1]) + truncatedMarker, true
+}
+
+// clipSpans drops the parts of spans past the end of a truncated title
+func clipSpans(spans [][2]int, end int) [][2]int {
+	var clipped [][2]int
+
+	for _, span := range spans {
+		if span[0] < end {
+			clipped = append(clipped, [2]int{span[0], minInt(span[1], end)})
+		}
+	}
+
+	return clipped
+}
@@ -0,0 +1,152 @@
+// MIT License
+//
+// Copyright (c) 2019 Gregory Meyer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"unicode"
+)
+
+type trigramPostings map[int]*Record
+
+// TrigramIndex maps each sequence of three case-folded runes to the Records
+// whose titles contain it, so a substring search only has to check Records
+// that contain every trigram of the substring
+type TrigramIndex struct {
+	postings map[string]trigramPostings
+}
+
+// NewTrigramIndex creates an empty TrigramIndex
+func NewTrigramIndex() *TrigramIndex {
+	return &TrigramIndex{make(map[string]trigramPostings)}
+}
+
+// Add indexes a Record by its title
+func (t *TrigramIndex) Add(record *Record) {
+	for _, trigram := range trigrams(record.title) {
+		postings, ok := t.postings[trigram]
+
+		if !ok {
+			postings = make(trigramPostings)
+			t.postings[trigram] = postings
+		}
+
+		postings[record.id] = record
+	}
+}
+
+// Remove stops indexing a Record. It must be called before the Record's title
+// changes.
+func (t *TrigramIndex) Remove(record *Record) {
+	for _, trigram := range trigrams(record.title) {
+		postings, ok := t.postings[trigram]
+
+		if !ok {
+			continue
+		}
+
+		delete(postings, record.id)
+
+		if len(postings) == 0 {
+			delete(t.postings, trigram)
+		}
+	}
+}
+
+// Candidates returns the Records whose titles could contain a substring, case
+// insensitively. Returns false if the substring is too short to narrow them.
+func (t *TrigramIndex) Candidates(substr string) (map[int]*Record, bool) {
+	grams := trigrams(substr)
+
+	if len(grams) == 0 {
+		return nil, false
+	}
+
+	// start from the rarest trigram to keep the intersection small
+	rarest := t.postings[grams[0]]
+
+	for _, trigram := range grams[1:] {
+		if postings := t.postings[trigram]; len(postings) < len(rarest) {
+			rarest = postings
+		}
+	}
+
+	candidates := make(map[int]*Record, len(rarest))
+
+	for id, record := range rarest {
+		candidates[id] = record
+	}
+
+	for _, trigram := range grams {
+		postings := t.postings[trigram]
+
+		for id := range candidates {
+			if _, ok := postings[id]; !ok {
+				delete(candidates, id)
+			}
+		}
+	}
+
+	return candidates, true
+}
+
+// trigrams returns every distinct sequence of three case-folded runes in a
+// string
+func trigrams(s string) []string {
+	runes := []rune(s)
+
+	if len(runes) < 3 {
+		return nil
+	}
+
+	for i, r := range runes {
+		runes[i] = foldRune(r)
+	}
+
+	seen := make(map[string]bool)
+	var grams []string
+
+	for i := 0; i+3 <= len(runes); i++ {
+		trigram := string(runes[i : i+3])
+
+		if !seen[trigram] {
+			seen[trigram] = true
+			grams = append(grams, trigram)
+		}
+	}
+
+	return grams
+}
+
+// foldRune maps every rune that case insensitive matching treats as equal to
+// the same rune, the smallest of them
+func foldRune(r rune) rune {
+	smallest := r
+
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f < smallest {
+			smallest = f
+		}
+	}
+
+	return smallest
+}
@@ -0,0 +1,203 @@
+// MIT License
+//
+// Copyright (c) 2019 Gregory Meyer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// unattributedUser is the user of ratings given while no user was set, and of
+// ratings read from files saved before ratings had users
+const unattributedUser = ""
+
+// unattributedName is how the unattributed user is saved and printed
+const unattributedName = "-"
+
+// RatingSummary aggregates the ratings users have given a Record
+type RatingSummary struct {
+	Mean   Rating
+	Median Rating
+	Count  int
+
+	// Disagreement is the standard deviation of the ratings
+	Disagreement Rating
+}
+
+// String prints the median, count and disagreement of a RatingSummary, the
+// mean being the rating of the Record
+func (s RatingSummary) String() string {
+	return fmt.Sprintf("(%d ratings, median %s, disagreement %s)", s.Count, s.Median, s.Disagreement)
+}
+
+// SetRating sets the rating a user gives this Record, which must be on a
+// RatingScale. NoRating takes the user's rating away. The rating of the Record
+// is the mean of the ratings of every user.
+func (r *Record) SetRating(user string, newRating Rating, scale RatingScale) Error {
+	if err := scale.Check(newRating); err != nil {
+		return err
+	}
+
+	if newRating == NoRating {
+		delete(r.ratings, user)
+	} else {
+		if r.ratings == nil {
+			r.ratings = make(map[string]Rating)
+		}
+
+		r.ratings[user] = newRating
+	}
+
+	r.rating = r.Summary().Mean
+
+	return nil
+}
+
+// Ratings returns the users who rated this Record, sorted by name in
+// ascending order with the unattributed user first, and their ratings
+func (r *Record) Ratings() ([]string, []Rating) {
+	users := make([]string, 0, len(r.ratings))
+
+	for user := range r.ratings {
+		users = append(users, user)
+	}
+
+	sort.Strings(users)
+
+	ratings := make([]Rating, len(users))
+
+	for i, user := range users {
+		ratings[i] = r.ratings[user]
+	}
+
+	return users, ratings
+}
+
+// Summary aggregates the ratings of this Record. The mean and median are
+// NoRating if no user rated it.
+func (r *Record) Summary() RatingSummary {
+	_, ratings := r.Ratings()
+
+	if len(ratings) == 0 {
+		return RatingSummary{NoRating, NoRating, 0, 0}
+	}
+
+	sort.Slice(ratings, func(i, j int) bool {
+		return ratings[i] < ratings[j]
+	})
+
+	total := 0.0
+
+	for _, rating := range ratings {
+		total += float64(rating)
+	}
+
+	mean := total / float64(len(ratings))
+	median := float64(ratings[len(ratings)/2])
+
+	if len(ratings)%2 == 0 {
+		median = float64(ratings[len(ratings)/2-1]+ratings[len(ratings)/2]) / 2
+	}
+
+	variance := 0.0
+
+	for _, rating := range ratings {
+		variance += (float64(rating) - mean) * (float64(rating) - mean)
+	}
+
+	variance /= float64(len(ratings))
+
+	return RatingSummary{
+		Rating(math.Round(mean)),
+		Rating(math.Round(median)),
+		len(ratings),
+		Rating(math.Round(math.Sqrt(variance))),
+	}
+}
+
+// ratingNote describes the ratings of a Record rated by more than one user,
+// to follow the Record when it is printed, or returns an empty string
+func ratingNote(record *Record) string {
+	if len(record.ratings) < 2 {
+		return ""
+	}
+
+	return record.Summary().String()
+}
+
+// saveRatings writes a line for the rating each user gives this Record, to
+// follow the line of the Record itself, unless the only rating is
+// unattributed, which the line of the Record holds already
+func (r *Record) saveRatings(writer io.Writer) {
+	if _, ok := r.ratings[unattributedUser]; ok && len(r.ratings) == 1 {
+		return
+	}
+
+	users, ratings := r.Ratings()
+
+	for i, user := range users {
+		FprintfOrPanic(writer, "rating %s %s\n", userName(user), ratings[i])
+	}
+}
+
+// restoreRating reads the rating of a user from the rest of a line written by
+// saveRatings. Returns false if the line is malformed.
+func (r *Record) restoreRating(line string, scale RatingScale) bool {
+	fields := strings.Fields(line)
+
+	if len(fields) != 2 {
+		return false
+	}
+
+	user := fields[0]
+
+	if user == unattributedName {
+		user = unattributedUser
+	}
+
+	rating, err := ParseRating(fields[1])
+
+	if _, ok := r.ratings[user]; ok || err != nil || rating == NoRating {
+		return false
+	}
+
+	return r.SetRating(user, rating, scale) == nil
+}
+
+// userName prints a user, or unattributedName for the unattributed user
+func userName(user string) string {
+	if user == unattributedUser {
+		return unattributedName
+	}
+
+	return user
+}
+
+// isUserName returns true if a name can be a user: a single word that isn't
+// unattributedName
+func isUserName(name string) bool {
+	return name != "" && name != unattributedName && len(strings.Fields(name)) == 1
+}
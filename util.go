@@ -26,11 +26,19 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 )
 
+// now returns the current time. Replays stop it so that transcripts come out
+// the same every time.
+var now = time.Now
+
 // ErrInvalidFile is the error message when an rA command encounters a malformed file.
 const ErrInvalidFile = "Invalid data found in file!"
 
@@ -204,3 +212,63 @@ func SkipWhitespace(reader *bufio.Reader) {
 		}
 	}
 }
+
+// IsTerminal returns true if a file is a terminal rather than a pipe or a
+// regular file
+func IsTerminal(file *os.File) bool {
+	info, err := file.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
+
+// writeFileAtomically replaces the contents of a file by writing them to a
+// temporary file in the same directory, then renaming it over the original
+func writeFileAtomically(filename string, data []byte) error {
+	temp, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".tmp")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(temp.Name())
+
+	if err := temp.Chmod(0644); err != nil {
+		_ = temp.Close()
+
+		return err
+	}
+
+	if _, err := temp.Write(data); err != nil {
+		_ = temp.Close()
+
+		return err
+	}
+
+	if err := temp.Sync(); err != nil {
+		_ = temp.Close()
+
+		return err
+	}
+
+	if err := temp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(temp.Name(), filename)
+}
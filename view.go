@@ -0,0 +1,188 @@
+// MIT License
+//
+// Copyright (c) 2019 Gregory Meyer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// View is a dated viewing (or reading, or listening) of a Record, with an
+// optional rating and note. A View that wasn't rated has NoRating.
+type View struct {
+	date   time.Time
+	rating Rating
+	note   string
+}
+
+func (v View) String() string {
+	text := v.date.Format(fmtDate) + " " + v.rating.String()
+
+	if v.note != "" {
+		text += " " + v.note
+	}
+
+	return text
+}
+
+// AddView logs a View of this Record, keeping Views in order of date. The
+// rating must be on a RatingScale.
+func (r *Record) AddView(date time.Time, rating Rating, note string, scale RatingScale) Error {
+	if err := scale.Check(rating); err != nil {
+		return err
+	}
+
+	i := sort.Search(len(r.views), func(i int) bool {
+		return r.views[i].date.After(date)
+	})
+
+	r.views = append(r.views, View{})
+	copy(r.views[i+1:], r.views[i:])
+	r.views[i] = View{date, rating, note}
+
+	return nil
+}
+
+// Views returns every View of this Record, oldest first. The slice must not be
+// modified.
+func (r *Record) Views() []View {
+	return r.views
+}
+
+// LastViewed returns the date of the latest View of this Record. Returns false
+// if it has never been viewed.
+func (r *Record) LastViewed() (time.Time, bool) {
+	if len(r.views) == 0 {
+		return time.Time{}, false
+	}
+
+	return r.views[len(r.views)-1].date, true
+}
+
+// saveViews writes a line for each View of this Record, to follow the line of
+// the Record itself
+func (r *Record) saveViews(writer io.Writer) {
+	for _, view := range r.views {
+		FprintfOrPanic(writer, "view %s %s", view.date.Format(fmtDate), view.rating)
+
+		if view.note != "" {
+			FprintfOrPanic(writer, " %s", view.note)
+		}
+
+		FprintfOrPanic(writer, "\n")
+	}
+}
+
+// restoreView reads a View from the rest of a line written by saveViews, with
+// a rating on a RatingScale. Returns false if the line is malformed.
+func (r *Record) restoreView(line string, scale RatingScale) bool {
+	fields := strings.SplitN(strings.TrimSpace(line), " ", 3)
+
+	if len(fields) < 2 {
+		return false
+	}
+
+	date, err := time.Parse(fmtDate, fields[0])
+
+	if err != nil {
+		return false
+	}
+
+	rating, err := ParseRating(fields[1])
+
+	if err != nil {
+		return false
+	}
+
+	note := ""
+
+	if len(fields) == 3 {
+		note = strings.TrimSpace(fields[2])
+	}
+
+	return r.AddView(date, rating, note, scale) == nil
+}
+
+// RecentlyViewed returns up to limit Records in a Library that have been
+// viewed, most recently viewed first. Records last viewed on the same date
+// are sorted by title in ascending order.
+func (l *Library) RecentlyViewed(limit int) []*Record {
+	var viewed []*Record
+
+	for _, record := range l.sortedRecords() {
+		if _, ok := record.LastViewed(); ok {
+			viewed = append(viewed, record)
+		}
+	}
+
+	sort.SliceStable(viewed, func(i, j int) bool {
+		first, _ := viewed[i].LastViewed()
+		second, _ := viewed[j].LastViewed()
+
+		return first.After(second)
+	})
+
+	if len(viewed) > limit {
+		viewed = viewed[:limit]
+	}
+
+	return viewed
+}
+
+// NotViewedSince returns the Records in a Library that haven't been viewed on
+// or after a date, least recently viewed first, starting with the Records that
+// have never been viewed. Records last viewed on the same date are sorted by
+// title in ascending order.
+func (l *Library) NotViewedSince(date time.Time) []*Record {
+	var stale []*Record
+
+	for _, record := range l.sortedRecords() {
+		if last, ok := record.LastViewed(); !ok || last.Before(date) {
+			stale = append(stale, record)
+		}
+	}
+
+	sort.SliceStable(stale, func(i, j int) bool {
+		first, _ := stale[i].LastViewed()
+		second, _ := stale[j].LastViewed()
+
+		return first.Before(second)
+	})
+
+	return stale
+}
+
+// lastViewedNote describes when a Record was last viewed, to follow the
+// Record when it is printed
+func lastViewedNote(record *Record) string {
+	last, ok := record.LastViewed()
+
+	if !ok {
+		return "(never viewed)"
+	}
+
+	return fmt.Sprintf("(last viewed %s)", last.Format(fmtDate))
+}